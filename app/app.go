@@ -4,39 +4,96 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"syscall"
 	"time"
 
+	"TraineeGolangTestTask/models"
 	"TraineeGolangTestTask/repositories"
 	"github.com/gin-gonic/gin"
 )
 
 const (
 	EnvAppPageSize           = "APP_PAGE_SIZE"
+	EnvAppMaxUploadErrors    = "APP_MAX_UPLOAD_ERRORS"
+	EnvAppDataDirectory      = "APP_DATA_DIR"
+	EnvAppUploadWorkers      = "APP_UPLOAD_WORKERS"
+	EnvAppBankDirectory      = "APP_BANK_DIRECTORY"
+	EnvAppRejectedRowsTtl    = "APP_REJECTED_ROWS_TTL"
 	EnvGinMaxMultipartMemory = "GIN_MAX_MULTIPART_MEMORY"
 	EnvGinShutdownTimeout    = "GIN_SHUTDOWN_TIMEOUT"
 
 	DefaultAppPageSize           = 30
+	DefaultAppMaxUploadErrors    = 100
+	DefaultAppUploadWorkers      = 2
+	DefaultAppRejectedRowsTtl    = 24      // hours
 	DefaultGinMaxMultipartMemory = 8 << 22 // 32 mb
 	DefaultGinShutdownTimeout    = 5
 
 	MaxRowsPerDbCreateRequest = 2500
+
+	// MaxRowsPerDbCopyRequest is the batch size used for large files. Such batches
+	// are saved using COPY once MinRowsForDbCopyRequest rows of the file are read.
+	MaxRowsPerDbCopyRequest = 25000
+	MinRowsForDbCopyRequest = 4 * MaxRowsPerDbCreateRequest
+
+	// DefaultUploadFilename is used if the name of a file sent
+	// as the request body is not specified.
+	DefaultUploadFilename = "upload.csv"
+
+	// exportFlushRows is the number of transactions written to the
+	// NDJSON and XLSX exports between flushes of the response.
+	exportFlushRows = 1000
+
+	ndjsonContentType  = "application/x-ndjson"
+	xlsxContentType    = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	parquetContentType = "application/vnd.apache.parquet"
 )
 
 type Application struct {
-	PageSize              int
+	PageSize        int
+	MaxUploadErrors int
+
+	// DataDirectory is used for storing files produced by uploads.
+	// The temporary directory is used if it is empty.
+	DataDirectory string
+
+	// UploadWorkers is the number of files uploaded in the asynchronous
+	// mode which are processed at the same time.
+	UploadWorkers int
+
+	// RejectedRowsTtl is how long the files with rejected rows can be downloaded,
+	// they are removed afterwards. DefaultAppRejectedRowsTtl is used if it is zero.
+	RejectedRowsTtl time.Duration
+
+	// BankDirectory is used to add names of the payee banks to the JSON
+	// output. The names are not added if it is nil.
+	BankDirectory models.BankDirectory
+
 	TransactionRepository repositories.TransactionRepository
+	UploadJobRepository   repositories.UploadJobRepository
+
+	uploadWorkers *uploadWorkerPool
 }
 
 func (a *Application) Execute(addr string) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	err := a.startUploadWorkers(a.getUploadWorkers())
+	if err != nil {
+		return err
+	}
+
+	go a.cleanUpRejectedRowsFiles(ctx)
+
 	router := a.configureRouter(gin.Default())
 	server := &http.Server{
 		Addr:    addr,
@@ -64,8 +121,13 @@ func (a *Application) Execute(addr string) error {
 	// inform the server it has n seconds to finish the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(shutdownTimeoutSec)*time.Second)
 	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		return errors.New(fmt.Sprintf("Server forced to shut down: %v", err))
+	shutdownErr := server.Shutdown(ctx)
+
+	// running uploads are either finished or queued again until the next start,
+	// the workers are cancelled at once if the shutdown has already timed out
+	a.stopUploadWorkers(ctx)
+	if shutdownErr != nil {
+		return errors.New(fmt.Sprintf("Server forced to shut down: %v", shutdownErr))
 	}
 
 	log.Println("Server exiting")
@@ -80,9 +142,25 @@ func (a *Application) configureRouter(router *gin.Engine) *gin.Engine {
 
 func (a *Application) addRoutes(r *gin.Engine) {
 	apiTransactions := r.Group("/api/transactions")
+	apiTransactions.GET("", a.handleTransactions)
 	apiTransactions.GET("/csv", a.handleTransactionsAsCsv)
 	apiTransactions.GET("/json", a.handleTransactionsAsJson)
+	apiTransactions.GET("/ndjson", a.handleTransactionsAsNdjson)
+	apiTransactions.GET("/xlsx", a.handleTransactionsAsXlsx)
+	apiTransactions.GET("/parquet", a.handleTransactionsAsParquet)
 	apiTransactions.POST("/upload", a.handleTransactionsUpload)
+	apiTransactions.POST("/validate", a.handleTransactionsValidate)
+	apiTransactions.GET("/rejected/:id", a.handleRejectedRowsDownload)
+
+	apiImports := r.Group("/api/imports")
+	apiImports.GET("", a.handleImports)
+	apiImports.GET("/:id", a.handleImport)
+	apiImports.GET("/:id/transactions", a.handleImportTransactions)
+	apiImports.DELETE("/:id", a.handleImportDelete)
+	apiImports.POST("/:id/revert", a.handleImportRevert)
+
+	apiUploads := r.Group("/api/uploads")
+	apiUploads.GET("/:id", a.handleUploadJob)
 }
 
 func (a *Application) sendInternalError(c *gin.Context, message string) {
@@ -99,6 +177,165 @@ func (a *Application) sendBadRequest(c *gin.Context, message string) {
 	}
 }
 
+func (a *Application) sendNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"message": "not found"})
+}
+
+// parsePageParameter returns the number of the requested page. If the
+// parameter is invalid, the error response is sent and ok is false.
+func (a *Application) parsePageParameter(c *gin.Context) (page int, ok bool) {
+	pageParameter := c.DefaultQuery("page", "1")
+	page, err := strconv.Atoi(pageParameter)
+	if err != nil {
+		a.sendBadRequest(c, "the \"page\" parameter is required to be an integer number")
+		log.Println(err)
+		return 0, false
+	}
+
+	if page <= 0 {
+		a.sendBadRequest(c, "the \"page\" parameter is required to be a positive integer number")
+		return 0, false
+	}
+
+	return page, true
+}
+
+// sendPage responds with the results of the page and numbers of the
+// neighbour pages. The next page exists if the current page is full.
+func (a *Application) sendPage(c *gin.Context, page, count int, results interface{}) {
+	var (
+		previousPage *int
+		nextPage     *int
+	)
+	if page-1 > 0 {
+		previousPage = new(int)
+		*previousPage = page - 1
+	}
+
+	if count == a.PageSize {
+		nextPage = new(int)
+		*nextPage = page + 1
+	}
+
+	c.JSON(
+		http.StatusOK, gin.H{
+			"count":         count,
+			"next_page":     nextPage,
+			"previous_page": previousPage,
+			"results":       results,
+		},
+	)
+}
+
+// openUploadedFile opens the uploaded file, which is either sent in the "file"
+// field of the multipart form, or as the request body of the "text/csv" type, of
+// a JSON type or of a type of the compressed data. If the file cannot be opened, the error
+// response is sent and ok is false.
+func (a *Application) openUploadedFile(c *gin.Context) (data uploadedData, filename string, ok bool) {
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch contentType {
+	case "text/csv",
+		"application/json",
+		ndjsonContentType,
+		"application/gzip",
+		"application/x-gzip",
+		"application/zip",
+		"application/x-zip-compressed":
+		return a.openRequestBody(c, contentType)
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return uploadedData{}, "", false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return uploadedData{}, "", false
+	}
+
+	return uploadedData{ReadCloser: file, ContentType: fileHeader.Header.Get("Content-Type")}, fileHeader.Filename, true
+}
+
+// openRequestBody returns the request body, so that it is streamed to the
+// parser without buffering. The name of the file may be sent in the "filename"
+// parameter or in the Content-Disposition header.
+func (a *Application) openRequestBody(c *gin.Context, contentType string) (uploadedData, string, bool) {
+	filename := c.Query("filename")
+	if filename == "" {
+		_, params, err := mime.ParseMediaType(c.GetHeader("Content-Disposition"))
+		if err == nil {
+			filename = params["filename"]
+		}
+	}
+
+	if filename == "" {
+		filename = DefaultUploadFilename
+	}
+
+	if c.GetHeader("Content-Encoding") == "gzip" {
+		contentType = "application/gzip"
+	}
+
+	// the request body is closed by the server
+	return uploadedData{ReadCloser: io.NopCloser(c.Request.Body), ContentType: contentType}, filename, true
+}
+
+// sendInvalidFileData responds with all errors found in the rows of the uploaded file.
+func (a *Application) sendInvalidFileData(c *gin.Context, result ImportResult) {
+	c.JSON(
+		http.StatusUnprocessableEntity, gin.H{
+			"message":          errInvalidFileData.Error(),
+			"errors":           result.Errors,
+			"errors_truncated": result.ErrorsTruncated,
+		},
+	)
+}
+
+// sendDuplicateFile responds with the import of the file which was uploaded before.
+func (a *Application) sendDuplicateFile(c *gin.Context, err *duplicateFileError) {
+	c.JSON(
+		http.StatusConflict, gin.H{
+			"message":         err.Error(),
+			"previous_import": err.Previous,
+		},
+	)
+}
+
+func (a *Application) getMaxUploadErrors() int {
+	if a.MaxUploadErrors > 0 {
+		return a.MaxUploadErrors
+	}
+
+	return DefaultAppMaxUploadErrors
+}
+
+func (a *Application) getUploadWorkers() int {
+	if a.UploadWorkers > 0 {
+		return a.UploadWorkers
+	}
+
+	return DefaultAppUploadWorkers
+}
+
+func (a *Application) getRejectedRowsTtl() time.Duration {
+	if a.RejectedRowsTtl > 0 {
+		return a.RejectedRowsTtl
+	}
+
+	return DefaultAppRejectedRowsTtl * time.Hour
+}
+
+func (a *Application) getDataDirectory() string {
+	if a.DataDirectory != "" {
+		return a.DataDirectory
+	}
+
+	return filepath.Join(os.TempDir(), "rest-api-app")
+}
+
 func setMaxMultipartMemoryOrDefault(router *gin.Engine, defaultValue int64) {
 	maxMultipartMemoryString := os.Getenv(EnvGinMaxMultipartMemory)
 	if maxMultipartMemoryString != "" {
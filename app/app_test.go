@@ -39,19 +39,35 @@ func SubTestApplication_addRoutes(t *testing.T, app *Application) {
 	_, router := gin.CreateTestContext(w)
 	app.addRoutes(router)
 	routes := router.Routes()
-	if len(routes) != 3 {
-		t.Errorf("expected routes count %d, actual %d", 3, len(routes))
+	if len(routes) != 15 {
+		t.Errorf("expected routes count %d, actual %d", 15, len(routes))
 	}
 
 	sort.Slice(
 		routes, func(i, j int) bool {
+			if routes[i].Path == routes[j].Path {
+				return routes[i].Method < routes[j].Method
+			}
+
 			return routes[i].Path < routes[j].Path
 		},
 	)
 
-	addRoutesAssertPathAndMethod(t, routes[0], "/api/transactions/csv", "GET")
-	addRoutesAssertPathAndMethod(t, routes[1], "/api/transactions/json", "GET")
-	addRoutesAssertPathAndMethod(t, routes[2], "/api/transactions/upload", "POST")
+	addRoutesAssertPathAndMethod(t, routes[0], "/api/imports", "GET")
+	addRoutesAssertPathAndMethod(t, routes[1], "/api/imports/:id", "DELETE")
+	addRoutesAssertPathAndMethod(t, routes[2], "/api/imports/:id", "GET")
+	addRoutesAssertPathAndMethod(t, routes[3], "/api/imports/:id/revert", "POST")
+	addRoutesAssertPathAndMethod(t, routes[4], "/api/imports/:id/transactions", "GET")
+	addRoutesAssertPathAndMethod(t, routes[5], "/api/transactions", "GET")
+	addRoutesAssertPathAndMethod(t, routes[6], "/api/transactions/csv", "GET")
+	addRoutesAssertPathAndMethod(t, routes[7], "/api/transactions/json", "GET")
+	addRoutesAssertPathAndMethod(t, routes[8], "/api/transactions/ndjson", "GET")
+	addRoutesAssertPathAndMethod(t, routes[9], "/api/transactions/parquet", "GET")
+	addRoutesAssertPathAndMethod(t, routes[10], "/api/transactions/rejected/:id", "GET")
+	addRoutesAssertPathAndMethod(t, routes[11], "/api/transactions/upload", "POST")
+	addRoutesAssertPathAndMethod(t, routes[12], "/api/transactions/validate", "POST")
+	addRoutesAssertPathAndMethod(t, routes[13], "/api/transactions/xlsx", "GET")
+	addRoutesAssertPathAndMethod(t, routes[14], "/api/uploads/:id", "GET")
 }
 
 func addRoutesAssertPathAndMethod(t *testing.T, route gin.RouteInfo, expectedPath, expectedMethod string) {
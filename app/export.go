@@ -0,0 +1,53 @@
+package app
+
+import (
+	"io"
+	"net/url"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+)
+
+// ParseTransactionFilters returns the filters given by the same parameters
+// as the query parameters of the transaction endpoints.
+func (a *Application) ParseTransactionFilters(parameters url.Values) ([]repositories.TransactionFilter, error) {
+	builder := a.TransactionRepository.NewFilterBuilder()
+	err := addFilterParameters(
+		builder,
+		func(key, defaultValue string) string {
+			if values := parameters[key]; len(values) > 0 {
+				return values[0]
+			}
+
+			return defaultValue
+		},
+		func(key string) []string {
+			return parameters[key]
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return builder.GetFilters(), nil
+}
+
+// ExportTransactionsAsParquet writes the filtered transactions to w as a Parquet file.
+// It is shared by the Parquet endpoint and the export command.
+func (a *Application) ExportTransactionsAsParquet(
+	w io.Writer, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) error {
+	parquetWriter := models.NewTransactionParquetWriter(w)
+	parquetWriter.SetProjection(projection)
+	err := parquetWriter.WriteHeader()
+	if err != nil {
+		return err
+	}
+
+	err = a.TransactionRepository.ForEach(filters, projection.FieldNames(), parquetWriter.Write)
+	if err != nil {
+		return err
+	}
+
+	return parquetWriter.Close()
+}
@@ -1,13 +1,11 @@
 package app
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
-	"strconv"
 	"strings"
 
 	"TraineeGolangTestTask/models"
@@ -15,162 +13,330 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// handleTransactions sends the filtered transactions in the format selected by the
+// "format" parameter or, if it is absent, by the Accept header. The formats are
+// provided by the registered serializers, see TransactionSerializer.
+func (a *Application) handleTransactions(c *gin.Context) {
+	format := c.DefaultQuery("format", "")
+	if format != "" {
+		serializer := findTransactionSerializer(format)
+		if serializer == nil {
+			a.sendBadRequest(
+				c, fmt.Sprintf(
+					"unknown format %q, the format should be one of: %s",
+					format, strings.Join(getTransactionFormats(), ", "),
+				),
+			)
+			return
+		}
+
+		a.serializeTransactions(c, serializer)
+		return
+	}
+
+	contentTypes := make([]string, len(transactionSerializers))
+	for i, serializer := range transactionSerializers {
+		contentTypes[i] = serializer.ContentType()
+	}
+
+	contentType := negotiateContentType(c.GetHeader("Accept"), contentTypes)
+	for _, serializer := range transactionSerializers {
+		if serializer.ContentType() == contentType {
+			a.serializeTransactions(c, serializer)
+			return
+		}
+	}
+
+	c.JSON(
+		http.StatusNotAcceptable, gin.H{
+			"message": "the accepted content types are not supported, the supported types are: " +
+				strings.Join(contentTypes, ", "),
+		},
+	)
+}
+
+// handleTransactionsAsJson is the alias of handleTransactions for JSON, NDJSON is
+// sent instead if the client prefers it by the Accept header.
 func (a *Application) handleTransactionsAsJson(c *gin.Context) {
-	pageParameter := c.DefaultQuery("page", "1")
-	page, err := strconv.Atoi(pageParameter)
+	if acceptsNdjson(c) {
+		a.handleTransactionsAsNdjson(c)
+		return
+	}
+
+	a.serializeTransactions(c, jsonTransactionSerializer{})
+}
+
+func (a *Application) handleTransactionsAsCsv(c *gin.Context) {
+	a.serializeTransactions(c, csvTransactionSerializer{})
+}
+
+func (a *Application) handleTransactionsAsNdjson(c *gin.Context) {
+	a.serializeTransactions(c, ndjsonTransactionSerializer{})
+}
+
+func (a *Application) handleTransactionsAsXlsx(c *gin.Context) {
+	a.serializeTransactions(c, xlsxTransactionSerializer{})
+}
+
+func (a *Application) handleTransactionsAsParquet(c *gin.Context) {
+	a.serializeTransactions(c, parquetTransactionSerializer{})
+}
+
+// serializeTransactions parses the filters and the fields, which are
+// the same for all formats, and sends the transactions by the serializer.
+func (a *Application) serializeTransactions(c *gin.Context, serializer TransactionSerializer) {
+	filterBuilder := a.TransactionRepository.NewFilterBuilder()
+	err := parseParameters(c, filterBuilder)
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
+
+	projection, err := models.ParseTransactionProjection(c.DefaultQuery("fields", ""))
 	if err != nil {
-		a.sendBadRequest(c, "the \"page\" parameter is required to be an integer number")
-		log.Println(err)
+		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	if page <= 0 {
-		a.sendBadRequest(c, "the \"page\" parameter is required to be a positive integer number")
+	serializer.Serialize(c, a, filterBuilder.GetFilters(), projection)
+}
+
+func (a *Application) handleTransactionsUpload(c *gin.Context) {
+	file, filename, ok := a.openUploadedFile(c)
+	if !ok {
 		return
 	}
 
-	filterBuilder := a.TransactionRepository.NewFilterBuilder()
-	err = parseParameters(c, filterBuilder)
+	defer file.Close()
+
+	rejectUnknownColumns, err := parseUnknownColumnsParameter(c)
 	if err != nil {
 		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	transactions := a.TransactionRepository.Filter(filterBuilder.GetFilters(), page, a.PageSize)
-	var (
-		previousPage *int
-		nextPage     *int
-	)
-	if page-1 > 0 {
-		previousPage = new(int)
-		*previousPage = page - 1
+	partial, err := parseUploadModeParameter(c)
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
 	}
 
-	transactionsLen := len(transactions)
-	if transactionsLen == a.PageSize {
-		nextPage = new(int)
-		*nextPage = page + 1
+	onConflict, err := repositories.ParseConflictStrategy(getUploadParameter(c, "on_conflict", "error"))
+	if err != nil {
+		a.sendBadRequest(c, fmt.Sprintf("invalid \"on_conflict\" parameter: %v", err))
+		return
 	}
 
-	c.JSON(
-		http.StatusOK, gin.H{
-			"count":         transactionsLen,
-			"next_page":     nextPage,
-			"previous_page": previousPage,
-			"results":       transactions,
-		},
-	)
-}
+	// force allows uploading a file which was already uploaded
+	force, err := parseBoolUploadParameter(c, "force")
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
 
-func (a *Application) handleTransactionsAsCsv(c *gin.Context) {
-	filterBuilder := a.TransactionRepository.NewFilterBuilder()
-	err := parseParameters(c, filterBuilder)
+	async, err := parseBoolUploadParameter(c, "async")
 	if err != nil {
 		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	writer := c.Writer
-	header := writer.Header()
-	header.Set("Transfer-Encoding", "chunked")
-	header.Set("Content-Type", "text/csv")
-	writer.WriteHeader(http.StatusOK)
-	flusher := writer.(http.Flusher)
-	flusher.Flush()
+	encodingName := getUploadParameter(c, "encoding", "")
+	textEncoding, err := GetTextEncoding(encodingName)
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
 
-	_, err = writer.Write([]byte("TransactionId,RequestId,TerminalId,PartnerObjectId,AmountTotal,AmountOriginal,CommissionPS,CommissionClient,CommissionProvider,DateInput,DatePost,Status,PaymentType,PaymentNumber,ServiceId,Service,PayeeId,PayeeName,PayeeBankMfo,PayeeBankAccount,PaymentNarrative\n"))
+	dialect, err := parseCsvDialectParameters(getUploadParameters(c))
 	if err != nil {
-		// return from the handler to trigger closing the connection
+		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	err = a.TransactionRepository.ForEach(
-		filterBuilder.GetFilters(), func(model models.Transaction) error {
-			_, err := writer.Write([]byte(fmt.Sprintf("%s\n", model.ToCsvRow())))
+	if async {
+		if !force {
+			// files sent as the request body are checked by the upload worker
+			err = a.checkDuplicateFile(file)
+			var duplicateErr *duplicateFileError
+			if errors.As(err, &duplicateErr) {
+				a.sendDuplicateFile(c, duplicateErr)
+				return
+			}
+
 			if err != nil {
-				return err
+				a.sendInternalError(c, err.Error())
+				return
 			}
+		}
+
+		job := &models.UploadJob{
+			FileName:             filename,
+			ContentType:          file.ContentType,
+			Uploader:             getUploader(c),
+			RejectUnknownColumns: rejectUnknownColumns,
+			Partial:              partial,
+			OnConflict:           string(onConflict),
+			Force:                force,
+			Encoding:             encodingName,
+			CsvDialect:           dialect,
+		}
+		err = a.createUploadJob(file, job)
+		if err == errUploadQueueFull {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"message": err.Error()})
+			return
+		}
 
-			flusher.Flush()
-			return nil
+		if err != nil {
+			a.sendInternalError(c, err.Error())
+			return
+		}
+
+		log.Printf("File %s was queued for upload as job %s.\n", filename, job.Id)
+		c.JSON(
+			http.StatusAccepted, gin.H{
+				"id":         job.Id,
+				"state":      job.State,
+				"status_url": fmt.Sprintf("/api/uploads/%s", job.Id),
+			},
+		)
+		return
+	}
+
+	result, err := a.importTransactions(
+		c.Request.Context(),
+		file,
+		ImportOptions{
+			RejectUnknownColumns: rejectUnknownColumns,
+			Partial:              partial,
+			MaxErrors:            a.getMaxUploadErrors(),
+			OnConflict:           onConflict,
+			Record:               &models.Import{FileName: filename, Uploader: getUploader(c)},
+			Force:                force,
+			Encoding:             textEncoding,
+			Dialect:              dialect,
 		},
 	)
+	var duplicateErr *duplicateFileError
+	if errors.As(err, &duplicateErr) {
+		a.sendDuplicateFile(c, duplicateErr)
+		return
+	}
+
 	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
+
+	if len(result.Errors) > 0 && (!partial || result.RejectedCount == 0) {
+		// in the partial mode, only errors in the header reject the whole file
+		a.sendInvalidFileData(c, result)
 		return
 	}
 
-	flusher.Flush()
+	log.Printf("File %s was uploaded.\n", filename)
+	if result.RejectedRowsId != "" {
+		result.RejectedRowsUrl = fmt.Sprintf("/api/transactions/rejected/%s", result.RejectedRowsId)
+	}
+
+	c.JSON(http.StatusCreated, result)
 }
 
-func (a *Application) handleTransactionsUpload(c *gin.Context) {
-	fileHeader, err := c.FormFile("file")
+// handleTransactionsValidate checks the uploaded file the same way as
+// handleTransactionsUpload does, but nothing is written to the database.
+func (a *Application) handleTransactionsValidate(c *gin.Context) {
+	file, _, ok := a.openUploadedFile(c)
+	if !ok {
+		return
+	}
+
+	defer file.Close()
+
+	rejectUnknownColumns, err := parseUnknownColumnsParameter(c)
 	if err != nil {
 		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	file, err := fileHeader.Open()
+	textEncoding, err := GetTextEncoding(getUploadParameter(c, "encoding", ""))
 	if err != nil {
-		a.sendInternalError(c, err.Error())
+		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-
-	// skip header of CSV file
-	scanner.Scan()
-
-	uploadedRowsCount := 0
-	err = a.TransactionRepository.UseTransaction(
-		func(repository repositories.TransactionRepository) error {
-			i := MaxRowsPerDbCreateRequest
-			for i == MaxRowsPerDbCreateRequest {
-				i = 0
-				var transactions []models.Transaction
-				for scanner.Scan() && i < MaxRowsPerDbCreateRequest {
-					text := scanner.Text()
-					if text == "" {
-						continue
-					}
-
-					transaction, err := models.NewTransactionFromCSVRow(strings.Split(text, ","))
-					if err != nil {
-						return errors.New(fmt.Sprintf("invalid file data: %v", err))
-					}
-
-					transactions = append(transactions, transaction)
-					i++
-				}
-
-				transactionCount := len(transactions)
-				if transactionCount > 0 {
-					err = repository.CreateBatch(transactions)
-					if err != nil {
-						return err
-					}
-
-					uploadedRowsCount += transactionCount
-				}
-
-				transactions = []models.Transaction{}
-			}
+	dialect, err := parseCsvDialectParameters(getUploadParameters(c))
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
 
-			return nil
+	result, err := a.importTransactions(
+		c.Request.Context(),
+		file,
+		ImportOptions{
+			RejectUnknownColumns: rejectUnknownColumns,
+			DryRun:               true,
+			MaxErrors:            a.getMaxUploadErrors(),
+			Encoding:             textEncoding,
+			Dialect:              dialect,
 		},
 	)
-
 	if err != nil {
 		a.sendBadRequest(c, err.Error())
 		return
 	}
 
-	// if err = scanner.Err(); err != nil {
-	// 	a.sendInternalError(c, err.Error())
-	// 	return
-	// }
+	rowErrors := result.Errors
+	if rowErrors == nil {
+		rowErrors = []models.RowError{}
+	}
+
+	conflictingIds := result.ConflictingIds
+	if conflictingIds == nil {
+		conflictingIds = []uint64{}
+	}
+
+	response := gin.H{
+		"valid":                     len(rowErrors) == 0 && len(conflictingIds) == 0,
+		"row_count":                 result.RowCount + result.RejectedCount,
+		"invalid_row_count":         result.RejectedCount,
+		"errors":                    rowErrors,
+		"errors_truncated":          result.ErrorsTruncated,
+		"conflicting_ids":           conflictingIds,
+		"conflicting_ids_truncated": result.ConflictingIdsTruncated,
+	}
+	if len(result.Files) > 0 {
+		response["files"] = result.Files
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (a *Application) handleRejectedRowsDownload(c *gin.Context) {
+	id := c.Param("id")
+	path, err := a.getRejectedRowsFilePath(id)
+	if err != nil {
+		a.sendNotFound(c)
+		return
+	}
+
+	c.FileAttachment(path, fmt.Sprintf("rejected-%s.csv", id))
+}
+
+func (a *Application) handleUploadJob(c *gin.Context) {
+	job, err := a.UploadJobRepository.FindById(c.Param("id"))
+	if err == repositories.ErrNotFound {
+		a.sendNotFound(c)
+		return
+	}
+
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return
+	}
+
+	if job.RejectedRowsId != "" {
+		job.RejectedRowsUrl = fmt.Sprintf("/api/transactions/rejected/%s", job.RejectedRowsId)
+	}
 
-	log.Printf("File %s was uploaded.\n", fileHeader.Filename)
-	c.JSON(http.StatusCreated, gin.H{"row_count": uploadedRowsCount})
+	c.JSON(http.StatusOK, job)
 }
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"TraineeGolangTestTask/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+func (a *Application) handleImports(c *gin.Context) {
+	page, ok := a.parsePageParameter(c)
+	if !ok {
+		return
+	}
+
+	imports, err := a.TransactionRepository.Imports().List(page, a.PageSize)
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return
+	}
+
+	a.sendPage(c, page, len(imports), imports)
+}
+
+func (a *Application) handleImport(c *gin.Context) {
+	id, ok := a.parseImportIdParameter(c)
+	if !ok {
+		return
+	}
+
+	record, err := a.TransactionRepository.Imports().FindById(id)
+	if err == repositories.ErrNotFound {
+		a.sendNotFound(c)
+		return
+	}
+
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// handleImportTransactions responds with transactions created or last updated by the import.
+func (a *Application) handleImportTransactions(c *gin.Context) {
+	id, ok := a.parseImportIdParameter(c)
+	if !ok {
+		return
+	}
+
+	page, ok := a.parsePageParameter(c)
+	if !ok {
+		return
+	}
+
+	_, err := a.TransactionRepository.Imports().FindById(id)
+	if err == repositories.ErrNotFound {
+		a.sendNotFound(c)
+		return
+	}
+
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return
+	}
+
+	filterBuilder := a.TransactionRepository.NewFilterBuilder()
+	err = filterBuilder.AddImportId(strconv.FormatUint(id, 10))
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
+
+	transactions := a.TransactionRepository.Filter(filterBuilder.GetFilters(), nil, page, a.PageSize)
+	a.BankDirectory.SetPayeeBankNames(transactions)
+	a.sendPage(c, page, len(transactions), transactions)
+}
+
+// handleImportDelete reverts changes of the import and removes the import itself.
+func (a *Application) handleImportDelete(c *gin.Context) {
+	id, ok := a.parseImportIdParameter(c)
+	if !ok {
+		return
+	}
+
+	result, err := a.TransactionRepository.Imports().Delete(id)
+	if err == repositories.ErrNotFound {
+		a.sendNotFound(c)
+		return
+	}
+
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted_count": result.Deleted, "restored_count": result.Restored})
+}
+
+// handleImportRevert removes transactions created by the import and restores
+// transactions updated by it, but keeps the import itself.
+func (a *Application) handleImportRevert(c *gin.Context) {
+	id, ok := a.parseImportIdParameter(c)
+	if !ok {
+		return
+	}
+
+	result, err := a.TransactionRepository.Imports().Revert(id)
+	if err == repositories.ErrNotFound {
+		a.sendNotFound(c)
+		return
+	}
+
+	if err == repositories.ErrAlreadyReverted {
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err != nil {
+		a.sendInternalError(c, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted_count": result.Deleted, "restored_count": result.Restored})
+}
+
+func (a *Application) parseImportIdParameter(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		a.sendNotFound(c)
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,392 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleImports(t *testing.T) {
+	transactionRepository := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: transactionRepository,
+	}
+
+	t.Run(
+		"UploadCreatesImport", func(t *testing.T) {
+			SubTestApplication_handleImports_UploadCreatesImport(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"409DuplicateFile", func(t *testing.T) {
+			SubTestApplication_handleImports_409DuplicateFile(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"200List", func(t *testing.T) {
+			SubTestApplication_handleImports_200List(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"200Revert", func(t *testing.T) {
+			SubTestApplication_handleImports_200Revert(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"200Delete", func(t *testing.T) {
+			SubTestApplication_handleImports_200Delete(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"404", func(t *testing.T) {
+			SubTestApplication_handleImports_404(t, &app, transactionRepository)
+		},
+	)
+}
+
+func SubTestApplication_handleImports_UploadCreatesImport(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+	c.Request.Header.Set("X-Uploader", "accountant")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.imports) != 1 {
+		t.Fatalf("expected imports count is %d, actual is %d", 1, len(repo.imports))
+	}
+
+	record := repo.imports[0]
+	checksum := sha256.Sum256([]byte(strings.Join(testData, "\n") + "\n"))
+	expected := models.Import{
+		Id:            1,
+		FileName:      "file.csv",
+		Checksum:      hex.EncodeToString(checksum[:]),
+		Uploader:      "accountant",
+		RowCount:      len(testTransactions),
+		InsertedCount: len(testTransactions),
+	}
+	if record.FinishedAt == nil {
+		t.Error("finish time of the import is not set")
+	}
+
+	record.StartedAt = time.Time{}
+	record.FinishedAt = nil
+	if !reflect.DeepEqual(record, expected) {
+		t.Errorf("expected import:\n%+v\nactual import:\n%+v", expected, record)
+	}
+
+	for _, transaction := range repo.models {
+		if transaction.ImportId == nil || *transaction.ImportId != record.Id {
+			t.Errorf("transaction %d does not refer to import %d", transaction.Id, record.Id)
+		}
+	}
+
+	response := struct {
+		ImportId uint64 `json:"import_id"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.ImportId != record.Id {
+		t.Errorf("expected import id %d, actual %d", record.Id, response.ImportId)
+	}
+}
+
+func SubTestApplication_handleImports_409DuplicateFile(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	upload := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = uploadTestRequestMock(testData)
+		c.Request.URL.RawQuery = query
+		app.handleTransactionsUpload(c)
+		return w
+	}
+
+	w := upload("on_conflict=skip")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d", http.StatusCreated, w.Code)
+	}
+
+	for _, query := range []string{"on_conflict=skip", "on_conflict=skip&async=true"} {
+		w = upload(query)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("expected status %d for %q, actual %d", http.StatusConflict, query, w.Code)
+		}
+
+		response := struct {
+			PreviousImport models.Import `json:"previous_import"`
+		}{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.PreviousImport.Id != 1 || response.PreviousImport.InsertedCount != len(testTransactions) {
+			t.Errorf("expected the previous import to be returned, actual %+v", response.PreviousImport)
+		}
+	}
+
+	w = upload("on_conflict=skip&force=true")
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, actual %d", http.StatusCreated, w.Code)
+	}
+
+	w = importsRequest(app.handleImportRevert, "1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	w = importsRequest(app.handleImportRevert, "2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	// reverted imports are not considered
+	w = upload("on_conflict=skip")
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, actual %d", http.StatusCreated, w.Code)
+	}
+}
+
+func SubTestApplication_handleImports_200List(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.imports = []models.Import{{Id: 1, FileName: "first.csv"}, {Id: 2, FileName: "second.csv"}}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.handleImports(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	response := struct {
+		Count   int             `json:"count"`
+		Results []models.Import `json:"results"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Count != 2 || response.Results[0].FileName != "second.csv" {
+		t.Errorf("expected the latest import first, actual %+v", response.Results)
+	}
+}
+
+func SubTestApplication_handleImports_200Revert(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	importsSetUpTransactions(repo)
+	w := importsRequest(app.handleImportRevert, "1")
+
+	importsAssertDeletedCount(t, w, 2)
+	if len(repo.models) != 1 || *repo.models[0].ImportId != 2 {
+		t.Errorf("expected only the transaction of import 2 to be kept, actual %+v", repo.models)
+	}
+
+	if len(repo.imports) != 2 || repo.imports[0].RevertedAt == nil {
+		t.Errorf("expected import 1 to be kept and marked as reverted, actual %+v", repo.imports)
+	}
+
+	w = importsRequest(app.handleImportRevert, "1")
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, actual %d", http.StatusConflict, w.Code)
+	}
+}
+
+func SubTestApplication_handleImports_200Delete(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	importsSetUpTransactions(repo)
+	w := importsRequest(app.handleImportDelete, "2")
+
+	importsAssertDeletedCount(t, w, 1)
+	if len(repo.models) != 2 {
+		t.Errorf("expected transactions count is %d, actual is %d", 2, len(repo.models))
+	}
+
+	if len(repo.imports) != 1 || repo.imports[0].Id != 1 {
+		t.Errorf("expected only import 1 to be kept, actual %+v", repo.imports)
+	}
+}
+
+func SubTestApplication_handleImports_404(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.imports = []models.Import{}
+	handlers := []gin.HandlerFunc{
+		app.handleImport,
+		app.handleImportTransactions,
+		app.handleImportDelete,
+		app.handleImportRevert,
+	}
+	for _, handler := range handlers {
+		for _, id := range []string{"1", "x"} {
+			w := importsRequest(handler, id)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("expected status %d for id %q, actual %d", http.StatusNotFound, id, w.Code)
+			}
+		}
+	}
+}
+
+func importsSetUpTransactions(repo *transactionRepositoryMock) {
+	firstId, secondId := uint64(1), uint64(2)
+	repo.imports = []models.Import{{Id: firstId}, {Id: secondId}}
+	repo.models = append([]models.Transaction{}, testTransactions...)
+	repo.models[0].ImportId = &firstId
+	repo.models[1].ImportId = &firstId
+	repo.models[2].ImportId = &secondId
+}
+
+func importsRequest(handler gin.HandlerFunc, id string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	handler(c)
+	return w
+}
+
+func importsAssertDeletedCount(t *testing.T, w *httptest.ResponseRecorder, expected int) {
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	response := struct {
+		DeletedCount int `json:"deleted_count"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.DeletedCount != expected {
+		t.Errorf("expected deleted count %d, actual %d", expected, response.DeletedCount)
+	}
+}
+
+type importRepositoryMock struct {
+	transactions *transactionRepositoryMock
+}
+
+func (m *importRepositoryMock) Create(record *models.Import) error {
+	record.Id = uint64(len(m.transactions.imports) + 1)
+	m.transactions.imports = append(m.transactions.imports, *record)
+	return nil
+}
+
+func (m *importRepositoryMock) Save(record *models.Import) error {
+	index := m.indexOf(record.Id)
+	if index == -1 {
+		return repositories.ErrNotFound
+	}
+
+	m.transactions.imports[index] = *record
+	return nil
+}
+
+func (m *importRepositoryMock) FindById(id uint64) (*models.Import, error) {
+	index := m.indexOf(id)
+	if index == -1 {
+		return nil, repositories.ErrNotFound
+	}
+
+	record := m.transactions.imports[index]
+	return &record, nil
+}
+
+func (m *importRepositoryMock) FindByChecksum(checksum string) (*models.Import, error) {
+	for i := len(m.transactions.imports) - 1; i >= 0; i-- {
+		record := m.transactions.imports[i]
+		if record.Checksum == checksum && record.RevertedAt == nil {
+			return &record, nil
+		}
+	}
+
+	return nil, repositories.ErrNotFound
+}
+
+func (m *importRepositoryMock) List(page, pageSize int) ([]models.Import, error) {
+	var imports []models.Import
+	for i := len(m.transactions.imports) - 1; i >= 0; i-- {
+		imports = append(imports, m.transactions.imports[i])
+	}
+
+	return imports, nil
+}
+
+func (m *importRepositoryMock) Delete(id uint64) (repositories.RevertResult, error) {
+	index := m.indexOf(id)
+	if index == -1 {
+		return repositories.RevertResult{}, repositories.ErrNotFound
+	}
+
+	m.transactions.imports = append(m.transactions.imports[:index], m.transactions.imports[index+1:]...)
+	return repositories.RevertResult{Deleted: m.deleteTransactions(id)}, nil
+}
+
+func (m *importRepositoryMock) Revert(id uint64) (repositories.RevertResult, error) {
+	index := m.indexOf(id)
+	if index == -1 {
+		return repositories.RevertResult{}, repositories.ErrNotFound
+	}
+
+	if m.transactions.imports[index].RevertedAt != nil {
+		return repositories.RevertResult{}, repositories.ErrAlreadyReverted
+	}
+
+	revertedAt := time.Now()
+	m.transactions.imports[index].RevertedAt = &revertedAt
+	return repositories.RevertResult{Deleted: m.deleteTransactions(id)}, nil
+}
+
+func (m *importRepositoryMock) deleteTransactions(importId uint64) int {
+	var kept []models.Transaction
+	for _, transaction := range m.transactions.models {
+		if transaction.ImportId == nil || *transaction.ImportId != importId {
+			kept = append(kept, transaction)
+		}
+	}
+
+	deleted := len(m.transactions.models) - len(kept)
+	m.transactions.models = kept
+	return deleted
+}
+
+func (m *importRepositoryMock) indexOf(id uint64) int {
+	for i, record := range m.transactions.imports {
+		if record.Id == id {
+			return i
+		}
+	}
+
+	return -1
+}
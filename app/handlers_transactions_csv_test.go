@@ -3,7 +3,10 @@ package app
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"TraineeGolangTestTask/models"
@@ -38,3 +41,183 @@ func TestApplication_handleTransactionsAsCsv_200(t *testing.T) {
 		t.Errorf("expected csv:\n%s\nactual csv:\n%s", expectedCsv, actualCsv)
 	}
 }
+
+func TestApplication_handleTransactionsAsCsv_RoundTripSpecialCharacters(t *testing.T) {
+	exported := []models.Transaction{testTransactions[0], testTransactions[1]}
+	exported[0].Service = "Поповнення, карток"
+	exported[0].PayeeName = "ТОВ \"Рога і копита\""
+	exported[0].PaymentNarrative = "Перерахування коштів,\nзгідно договору \"А11/27122\""
+	exported[1].PayeeName = "\"privat\""
+	exported[1].PaymentNarrative = "рядок 1\r\nрядок 2,\n\nрядок 4"
+	app := Application{
+		PageSize:              2,
+		TransactionRepository: newTransactionRepositoryMock(exported),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	app.handleTransactionsAsCsv(c)
+
+	csvData, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields are quoted and escaped, line breaks are written as they are
+	rows := []string{testData[0], testData[1], testData[2]}
+	fields := strings.Split(rows[1], ",")
+	fields[15] = `"Поповнення, карток"`
+	fields[17] = `"ТОВ ""Рога і копита"""`
+	fields[20] = "\"Перерахування коштів,\nзгідно договору \"\"А11/27122\"\"\""
+	rows[1] = strings.Join(fields, ",")
+	fields = strings.Split(rows[2], ",")
+	fields[17] = `"""privat"""`
+	fields[20] = "\"рядок 1\r\nрядок 2,\n\nрядок 4\""
+	rows[2] = strings.Join(fields, ",")
+	expectedCsv := strings.Join(rows, "\n") + "\n"
+	if string(csvData) != expectedCsv {
+		t.Errorf("expected csv:\n%q\nactual csv:\n%q", expectedCsv, string(csvData))
+	}
+
+	imported := newTransactionRepositoryMock([]models.Transaction{})
+	app.TransactionRepository = imported
+	requestMock, err := uploadTestRequestMock([]string{strings.TrimSuffix(string(csvData), "\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = requestMock
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(imported.models) != len(exported) {
+		t.Fatalf("expected transactions count is %d, actual is %d", len(exported), len(imported.models))
+	}
+
+	// CRLF inside quoted fields is read as LF, see models.TransactionCsvReader
+	exported[1].PaymentNarrative = strings.ReplaceAll(exported[1].PaymentNarrative, "\r\n", "\n")
+	for i, expected := range exported {
+		// the reference to the import is not exported
+		actual := imported.models[i]
+		actual.ImportId = nil
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected transaction:\n%+v\nactual transaction:\n%+v", expected, actual)
+		}
+	}
+}
+
+func TestApplication_handleTransactionsAsCsv_RoundTripExcelUaDialect(t *testing.T) {
+	exported := []models.Transaction{testTransactions[0], testTransactions[2]}
+	app := Application{
+		PageSize:              2,
+		TransactionRepository: newTransactionRepositoryMock(exported),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?dialect=excel-ua", nil)
+	app.handleTransactionsAsCsv(c)
+
+	csvData, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(csvData), "\n")
+	expectedRow := "3;20040;3508;1111;3,00;3,00;0,00;0,00;-0,01;17.08.2022 09:53:43;17.08.2022 12:53:44;accepted;card"
+	if !strings.HasPrefix(lines[0], "\ufeffTransactionId;RequestId;") || !strings.HasPrefix(lines[2], expectedRow) {
+		t.Fatalf("expected rows in the excel-ua dialect, actual csv:\n%s", csvData)
+	}
+
+	imported := newTransactionRepositoryMock([]models.Transaction{})
+	app.TransactionRepository = imported
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/?dialect=excel-ua", strings.NewReader(string(csvData)))
+	c.Request.Header.Set("Content-Type", "text/csv")
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(imported.models) != len(exported) {
+		t.Fatalf("expected transactions count is %d, actual is %d", len(exported), len(imported.models))
+	}
+
+	for i, expected := range exported {
+		actual := imported.models[i]
+		actual.ImportId = nil
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected transaction:\n%+v\nactual transaction:\n%+v", expected, actual)
+		}
+	}
+}
+
+func TestApplication_handleTransactionsAsCsv_400InvalidDialect(t *testing.T) {
+	app := Application{
+		PageSize:              2,
+		TransactionRepository: newTransactionRepositoryMock([]models.Transaction{testTransactions[0]}),
+	}
+
+	for _, query := range []string{"dialect=excel", "delimiter=%3B%3B", "delimiter=%3B&decimal_separator=%3B", "header=maybe"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		app.handleTransactionsAsCsv(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %q, actual %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
+
+func TestApplication_handleTransactionsAsCsv_200Fields(t *testing.T) {
+	repo := newTransactionRepositoryMock([]models.Transaction{testTransactions[0], testTransactions[1]})
+	app := Application{
+		PageSize:              2,
+		TransactionRepository: repo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=Status,transaction_id,AmountTotal", nil)
+	app.handleTransactionsAsCsv(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	expectedCsv := "Status,TransactionId,AmountTotal\naccepted,1,1.00\ndeclined,2,1.00\n"
+	if w.Body.String() != expectedCsv {
+		t.Errorf("expected csv:\n%s\nactual csv:\n%s", expectedCsv, w.Body.String())
+	}
+
+	expectedFields := []string{"Status", "Id", "AmountTotal"}
+	if !reflect.DeepEqual(repo.fields, expectedFields) {
+		t.Errorf("expected selected fields %v, actual %v", expectedFields, repo.fields)
+	}
+}
+
+func TestApplication_handleTransactionsAsCsv_400InvalidFields(t *testing.T) {
+	app := Application{
+		PageSize:              2,
+		TransactionRepository: newTransactionRepositoryMock([]models.Transaction{testTransactions[0]}),
+	}
+
+	for _, query := range []string{"fields=Status,Unknown", "fields=Status,status", "fields=Status,,Service"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		app.handleTransactionsAsCsv(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %q, actual %d", http.StatusBadRequest, query, w.Code)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"TraineeGolangTestTask/models"
@@ -26,6 +27,7 @@ func TestApplication_handleTransactionsAsJson_200GetAll(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
 
 	app.handleTransactionsAsJson(c)
 
@@ -63,6 +65,100 @@ func TestApplication_handleTransactionsAsJson_200GetAll(t *testing.T) {
 	}
 }
 
+func TestApplication_handleTransactionsAsJson_200PayeeBankNames(t *testing.T) {
+	app := Application{
+		PageSize:              5,
+		BankDirectory:         models.BankDirectory{testTransactions[0].PayeeBankMfo: "ПУМБ"},
+		TransactionRepository: newTransactionRepositoryMock([]models.Transaction{testTransactions[0], testTransactions[1]}),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.handleTransactionsAsJson(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	responseBody := struct {
+		Results []map[string]interface{} `json:"results"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &responseBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(responseBody.Results) != 2 {
+		t.Fatalf("expected 2 results, actual %d", len(responseBody.Results))
+	}
+
+	if responseBody.Results[0]["payee_bank_name"] != "ПУМБ" {
+		t.Errorf("expected the bank name \"ПУМБ\", actual %v", responseBody.Results[0]["payee_bank_name"])
+	}
+
+	// the name of an unknown bank is omitted
+	if _, ok := responseBody.Results[1]["payee_bank_name"]; ok {
+		t.Errorf("expected no bank name, actual %v", responseBody.Results[1]["payee_bank_name"])
+	}
+}
+
+func TestApplication_handleTransactionsAsJson_200Fields(t *testing.T) {
+	repo := newTransactionRepositoryMock([]models.Transaction{testTransactions[0]})
+	app := Application{
+		PageSize:              5,
+		BankDirectory:         models.BankDirectory{testTransactions[0].PayeeBankMfo: "ПУМБ"},
+		TransactionRepository: repo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=payee_bank_mfo,TransactionId", nil)
+
+	app.handleTransactionsAsJson(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	responseBody := struct {
+		Results []json.RawMessage `json:"results"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &responseBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bank name follows the selected fields
+	expected := `{"payee_bank_mfo":254751,"transaction_id":1,"payee_bank_name":"ПУМБ"}`
+	if len(responseBody.Results) != 1 || string(responseBody.Results[0]) != expected {
+		t.Errorf("expected results [%s], actual %s", expected, responseBody.Results)
+	}
+
+	expectedFields := []string{"PayeeBankMfo", "Id"}
+	if !reflect.DeepEqual(repo.fields, expectedFields) {
+		t.Errorf("expected selected fields %v, actual %v", expectedFields, repo.fields)
+	}
+}
+
+func TestApplication_handleTransactionsAsJson_400InvalidFields(t *testing.T) {
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: newTransactionRepositoryMock([]models.Transaction{testTransactions[0]}),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=id", nil)
+
+	app.handleTransactionsAsJson(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
 func TestApplication_handleTransactionsAsJson_400InvalidPage(t *testing.T) {
 	runTestApplication_handleTransactionsAsJson_400(t, "hello")
 }
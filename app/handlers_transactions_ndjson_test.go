@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleTransactionsAsNdjson_200(t *testing.T) {
+	app := Application{
+		PageSize:              1,
+		BankDirectory:         models.BankDirectory{testTransactions[0].PayeeBankMfo: "ПУМБ"},
+		TransactionRepository: newTransactionRepositoryMock(testTransactions),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	app.handleTransactionsAsNdjson(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	if w.Header().Get("Content-Type") != ndjsonContentType {
+		t.Errorf("expected content type %s, actual %s", ndjsonContentType, w.Header().Get("Content-Type"))
+	}
+
+	// all transactions are returned regardless of the page size
+	var actual []models.Transaction
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		transaction := models.Transaction{}
+		err := json.Unmarshal(scanner.Bytes(), &transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual = append(actual, transaction)
+	}
+
+	expected := append([]models.Transaction{}, testTransactions...)
+	expected[0].PayeeBankName = "ПУМБ"
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected transactions:\n%+v\nactual transactions:\n%+v", expected, actual)
+	}
+}
+
+func TestApplication_handleTransactionsAsJson_200AcceptNdjson(t *testing.T) {
+	repo := newTransactionRepositoryMock([]models.Transaction{testTransactions[0], testTransactions[1]})
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: repo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=transaction_id,status", nil)
+	c.Request.Header.Set("Accept", ndjsonContentType)
+
+	app.handleTransactionsAsJson(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	expected := "{\"transaction_id\":1,\"status\":\"accepted\"}\n{\"transaction_id\":2,\"status\":\"declined\"}\n"
+	if w.Body.String() != expected {
+		t.Errorf("expected body:\n%s\nactual body:\n%s", expected, w.Body.String())
+	}
+
+	if !reflect.DeepEqual(repo.fields, []string{"Id", "Status"}) {
+		t.Errorf("expected selected fields %v, actual %v", []string{"Id", "Status"}, repo.fields)
+	}
+}
+
+func TestApplication_handleTransactionsAsNdjson_400(t *testing.T) {
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: newTransactionRepositoryMock(testTransactions),
+	}
+
+	for _, query := range []string{"fields=unknown", "fields=Status,status"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+
+		app.handleTransactionsAsNdjson(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d for %q, actual %d", http.StatusBadRequest, query, w.Code)
+		}
+
+		if strings.Contains(w.Header().Get("Content-Type"), ndjsonContentType) {
+			t.Errorf("expected an error response for %q, actual %s", query, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleTransactionsAsParquet_200(t *testing.T) {
+	repo := newTransactionRepositoryMock(testTransactions)
+	app := Application{
+		PageSize:              1,
+		TransactionRepository: repo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=TransactionId,PayeeBankAccount", nil)
+
+	app.handleTransactionsAsParquet(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	if w.Header().Get("Content-Type") != parquetContentType {
+		t.Errorf("expected content type %s, actual %s", parquetContentType, w.Header().Get("Content-Type"))
+	}
+
+	body := w.Body.Bytes()
+	if !bytes.HasPrefix(body, []byte("PAR1")) || !bytes.HasSuffix(body, []byte("PAR1")) {
+		t.Errorf("expected a Parquet file, actual %q", body)
+	}
+
+	// the schema is written to the footer, which is not compressed
+	if !bytes.Contains(body, []byte("PayeeBankAccount")) || bytes.Contains(body, []byte("PaymentNarrative")) {
+		t.Errorf("expected 2 columns in the file, actual %q", body)
+	}
+
+	expectedFields := []string{"Id", "PayeeBankAccount"}
+	if !reflect.DeepEqual(repo.fields, expectedFields) {
+		t.Errorf("expected fields %v, actual %v", expectedFields, repo.fields)
+	}
+}
+
+func TestApplication_handleTransactionsAsParquet_400InvalidFields(t *testing.T) {
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: newTransactionRepositoryMock([]models.Transaction{testTransactions[0]}),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=unknown", nil)
+
+	app.handleTransactionsAsParquet(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleTransactions(t *testing.T) {
+	repo := newTransactionRepositoryMock(testTransactions)
+	app := Application{
+		PageSize:              1,
+		TransactionRepository: repo,
+	}
+
+	t.Run("Default", func(t *testing.T) { SubTestApplication_handleTransactions_Default(t, &app) })
+	t.Run("FormatParameter", func(t *testing.T) { SubTestApplication_handleTransactions_FormatParameter(t, &app) })
+	t.Run("AcceptHeader", func(t *testing.T) { SubTestApplication_handleTransactions_AcceptHeader(t, &app) })
+	t.Run("UnknownFormat", func(t *testing.T) { SubTestApplication_handleTransactions_UnknownFormat(t, &app) })
+	t.Run("NotAcceptable", func(t *testing.T) { SubTestApplication_handleTransactions_NotAcceptable(t, &app) })
+	t.Run("InvalidFields", func(t *testing.T) { SubTestApplication_handleTransactions_InvalidFields(t, &app) })
+	t.Run("RegisteredSerializer", func(t *testing.T) { SubTestApplication_handleTransactions_RegisteredSerializer(t, &app) })
+}
+
+func SubTestApplication_handleTransactions_Default(t *testing.T, app *Application) {
+	w := serveTestTransactions(app, "/", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), gin.MIMEJSON) {
+		t.Fatalf("expected JSON with status %d, actual %d %s", http.StatusOK, w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// the JSON is paginated
+	if !strings.Contains(w.Body.String(), `"results":[`) {
+		t.Errorf("expected a page, actual %s", w.Body.String())
+	}
+}
+
+func SubTestApplication_handleTransactions_FormatParameter(t *testing.T, app *Application) {
+	// the parameter takes precedence over the Accept header
+	w := serveTestTransactions(app, "/?format=csv&fields=TransactionId&delimiter=;", gin.MIMEJSON)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected CSV with status %d, actual %d %s", http.StatusOK, w.Code, w.Header().Get("Content-Type"))
+	}
+
+	expected := "TransactionId\n1\n2\n3\n"
+	if w.Body.String() != expected {
+		t.Errorf("expected %q, actual %q", expected, w.Body.String())
+	}
+}
+
+func SubTestApplication_handleTransactions_AcceptHeader(t *testing.T, app *Application) {
+	w := serveTestTransactions(app, "/?fields=TransactionId", "application/json;q=0.5, application/x-ndjson")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("expected NDJSON with status %d, actual %d %s", http.StatusOK, w.Code, w.Header().Get("Content-Type"))
+	}
+
+	expected := "{\"transaction_id\":1}\n{\"transaction_id\":2}\n{\"transaction_id\":3}\n"
+	if w.Body.String() != expected {
+		t.Errorf("expected %q, actual %q", expected, w.Body.String())
+	}
+}
+
+func SubTestApplication_handleTransactions_UnknownFormat(t *testing.T, app *Application) {
+	w := serveTestTransactions(app, "/?format=xml", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+
+	if !strings.Contains(w.Body.String(), "json, csv, ndjson, xlsx, parquet") {
+		t.Errorf("expected the formats in the message, actual %s", w.Body.String())
+	}
+}
+
+func SubTestApplication_handleTransactions_NotAcceptable(t *testing.T, app *Application) {
+	w := serveTestTransactions(app, "/", "application/xml")
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected status %d, actual %d", http.StatusNotAcceptable, w.Code)
+	}
+}
+
+func SubTestApplication_handleTransactions_InvalidFields(t *testing.T, app *Application) {
+	w := serveTestTransactions(app, "/?format=parquet&fields=unknown", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func SubTestApplication_handleTransactions_RegisteredSerializer(t *testing.T, app *Application) {
+	registered := append([]TransactionSerializer{}, transactionSerializers...)
+	defer func() { transactionSerializers = registered }()
+
+	RegisterTransactionSerializer(countTransactionSerializer{})
+	w := serveTestTransactions(app, "/", "text/plain")
+	if w.Code != http.StatusOK || w.Body.String() != "3" {
+		t.Errorf("expected the count of transactions, actual %d %s", w.Code, w.Body.String())
+	}
+}
+
+// countTransactionSerializer sends the count of the filtered transactions.
+type countTransactionSerializer struct{}
+
+func (countTransactionSerializer) Format() string {
+	return "count"
+}
+
+func (countTransactionSerializer) ContentType() string {
+	return "text/plain"
+}
+
+func (countTransactionSerializer) Serialize(
+	c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) {
+	count := 0
+	_ = a.TransactionRepository.ForEach(
+		filters, projection.FieldNames(), func(models.Transaction) error {
+			count++
+			return nil
+		},
+	)
+
+	c.String(http.StatusOK, "%d", count)
+}
+
+func serveTestTransactions(app *Application, target, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		c.Request.Header.Set("Accept", accept)
+	}
+
+	app.handleTransactions(c)
+	return w
+}
@@ -2,12 +2,14 @@ package app
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -28,6 +30,75 @@ func TestApplication_handleTransactionsUpload(t *testing.T) {
 			SubTestApplication_handleTransactionsUpload_201(t, &app, transactionRepository)
 		},
 	)
+	t.Run(
+		"201QuotedFields", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201QuotedFields(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201ReorderedColumns", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201ReorderedColumns(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201RawBody", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201RawBody(t, &app, transactionRepository, false)
+		},
+	)
+	t.Run(
+		"201GzipBody", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201RawBody(t, &app, transactionRepository, true)
+		},
+	)
+	t.Run(
+		"400InvalidGzipBody", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_400InvalidGzipBody(t, &app)
+		},
+	)
+	t.Run(
+		"201LargeFile", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201LargeFile(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201PartialMode", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201PartialMode(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201OnConflictSkip", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201OnConflict(
+				t, &app, transactionRepository, "skip", uploadResponseMock{3, 1, 2, 0},
+			)
+		},
+	)
+	t.Run(
+		"201OnConflictUpdate", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201OnConflict(
+				t, &app, transactionRepository, "update", uploadResponseMock{3, 1, 0, 2},
+			)
+		},
+	)
+	t.Run(
+		"400OnConflictError", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_400OnConflictError(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"400InvalidOnConflict", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_400InvalidOnConflict(t, &app)
+		},
+	)
+	t.Run(
+		"422UnknownColumnsRejected", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_422UnknownColumnsRejected(t, &app)
+		},
+	)
+	t.Run(
+		"422MissingColumns", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_422MissingColumns(t, &app)
+		},
+	)
 	t.Run(
 		"400NotMultipartRequest", func(t *testing.T) {
 			SubTestApplication_handleTransactionsUpload_400NotMultipartRequest(t, &app)
@@ -39,8 +110,18 @@ func TestApplication_handleTransactionsUpload(t *testing.T) {
 		},
 	)
 	t.Run(
-		"400InvalidCSVData", func(t *testing.T) {
-			SubTestApplication_handleTransactionsUpload_400InvalidCSVData(t, &app)
+		"422InvalidCSVData", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_422InvalidCSVData(t, &app)
+		},
+	)
+	t.Run(
+		"422MultipleInvalidRows", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_422MultipleInvalidRows(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"422ErrorsTruncated", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_422ErrorsTruncated(t, &app)
 		},
 	)
 }
@@ -90,6 +171,383 @@ func SubTestApplication_handleTransactionsUpload_201(t *testing.T, app *Applicat
 	}
 }
 
+func SubTestApplication_handleTransactionsUpload_201QuotedFields(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	requestMock, err := uploadTestRequestMock(
+		[]string{
+			testData[0],
+			"4,20050,3509,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698235,13980,Поповнення карток,14232155,\"pumb, \"\"ПУМБ\"\"\",254751,UA713451373919523,\"Перерахування коштів,\r\nзгідно договору\"\r",
+		},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = requestMock
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, actual %d", http.StatusCreated, w.Code)
+	}
+
+	if len(repo.models) != 1 {
+		t.Fatalf("expected transactions count is %d, actual is %d", 1, len(repo.models))
+	}
+
+	expectedPayeeName := "pumb, \"ПУМБ\""
+	if repo.models[0].PayeeName != expectedPayeeName {
+		t.Errorf("expected payee name %q, actual %q", expectedPayeeName, repo.models[0].PayeeName)
+	}
+
+	expectedPaymentNarrative := "Перерахування коштів,\nзгідно договору"
+	if repo.models[0].PaymentNarrative != expectedPaymentNarrative {
+		t.Errorf("expected payment narrative %q, actual %q", expectedPaymentNarrative, repo.models[0].PaymentNarrative)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201ReorderedColumns(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	requestMock, err := uploadTestRequestMock(
+		[]string{
+			"comment,transaction_id,request_id,terminal_id,partner_object_id,amount_total,amount_original,commission_ps,commission_client,commission_provider,date_input,date_post,status,payment_type,payment_number,service_id,service,payee_id,payee_name,payee_bank_mfo,payee_bank_account,payment_narrative",
+			"first," + testData[1],
+			"second," + testData[2],
+		},
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = requestMock
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, actual %d", http.StatusCreated, w.Code)
+	}
+
+	if len(repo.models) != 2 {
+		t.Fatalf("expected transactions count is %d, actual is %d", 2, len(repo.models))
+	}
+
+	for i, transaction := range testTransactions[:2] {
+		// the reference to the import is checked separately
+		actual := repo.models[i]
+		actual.ImportId = nil
+		if actual != transaction {
+			t.Errorf("expected transaction:\n%+v\nactual transaction:\n%+v", transaction, actual)
+		}
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201RawBody(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+	compressed bool,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	body := new(bytes.Buffer)
+	contentType := "text/csv"
+	if compressed {
+		contentType = "application/gzip"
+		writer := gzip.NewWriter(body)
+		_, _ = writer.Write([]byte(strings.Join(testData, "\n")))
+		_ = writer.Close()
+	} else {
+		body.WriteString(strings.Join(testData, "\r\n"))
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/?filename=daily.csv&on_conflict=skip", body)
+	c.Request.Header.Set("Content-Type", contentType)
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.models) != len(testTransactions) {
+		t.Errorf("expected transactions count is %d, actual is %d", len(testTransactions), len(repo.models))
+	}
+
+	if len(repo.imports) != 1 || repo.imports[0].FileName != "daily.csv" {
+		t.Errorf("expected import of file %q, actual %+v", "daily.csv", repo.imports)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_400InvalidGzipBody(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Join(testData, "\n")))
+	c.Request.Header.Set("Content-Type", "text/csv")
+	c.Request.Header.Set("Content-Encoding", "gzip")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201LargeFile(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	repo.copyBatchCount = 0
+
+	// the last batch is larger than MaxRowsPerDbCreateRequest and is saved using COPY
+	rowCount := MinRowsForDbCopyRequest + 2*MaxRowsPerDbCreateRequest
+	body := new(bytes.Buffer)
+	body.WriteString(testData[0])
+	fields := strings.SplitN(testData[1], ",", 2)
+	for i := 1; i <= rowCount; i++ {
+		body.WriteString(fmt.Sprintf("\n%d,%s", i, fields[1]))
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", body)
+	c.Request.Header.Set("Content-Type", "text/csv")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.models) != rowCount {
+		t.Errorf("expected transactions count is %d, actual is %d", rowCount, len(repo.models))
+	}
+
+	if repo.copyBatchCount != 1 {
+		t.Errorf("expected %d batch saved using COPY, actual %d", 1, repo.copyBatchCount)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201PartialMode(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	app.DataDirectory = t.TempDir()
+	defer func() {
+		app.DataDirectory = ""
+	}()
+
+	invalidRow := "2,20030,x,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 12:36:52,2022-08-12 15:36:53,declined,cash,PS16698215,13990,Поповнення карток,14332255,privat,255752,UA713461333619513,\"Перерахування коштів, А11/27123\""
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{testData[0], testData[1], invalidRow, "1,2,3", testData[3]})
+	c.Request.URL.RawQuery = "mode=partial"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	response := partialUploadResponseMock{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.RowCount != 2 {
+		t.Errorf("expected row count %d, actual %d", 2, response.RowCount)
+	}
+
+	if response.RejectedCount != 2 {
+		t.Errorf("expected rejected count %d, actual %d", 2, response.RejectedCount)
+	}
+
+	if len(response.Errors) != 2 {
+		t.Errorf("expected errors count %d, actual %d", 2, len(response.Errors))
+	}
+
+	if len(repo.models) != 2 || repo.models[0].Id != 1 || repo.models[1].Id != 3 {
+		t.Errorf("expected transactions 1 and 3 to be saved, actual %+v", repo.models)
+	}
+
+	expectedUrlPrefix := "/api/transactions/rejected/"
+	if !strings.HasPrefix(response.RejectedRowsUrl, expectedUrlPrefix) {
+		t.Fatalf("expected rejected rows url starting with %q, actual %q", expectedUrlPrefix, response.RejectedRowsUrl)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, response.RejectedRowsUrl, nil)
+	c.Params = gin.Params{{Key: "id", Value: strings.TrimPrefix(response.RejectedRowsUrl, expectedUrlPrefix)}}
+
+	app.handleRejectedRowsDownload(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	expectedCsv := testData[0] + ",Error\n" +
+		invalidRow + ",\"line 3, column TerminalId: strconv.ParseUint: parsing \"\"x\"\": invalid syntax\"\n" +
+		"1,2,3,line 4: wrong number of fields\n"
+	if w.Body.String() != expectedCsv {
+		t.Errorf("expected csv:\n%s\nactual csv:\n%s", expectedCsv, w.Body.String())
+	}
+}
+
+func TestApplication_handleRejectedRowsDownload_404(t *testing.T) {
+	app := Application{DataDirectory: t.TempDir()}
+	for _, id := range []string{"0123456789abcdef0123456789abcdef", "..", "../../etc/passwd"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+
+		app.handleRejectedRowsDownload(c)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d for id %q, actual %d", http.StatusNotFound, id, w.Code)
+		}
+	}
+}
+
+type partialUploadResponseMock struct {
+	RowCount        int               `json:"row_count"`
+	RejectedCount   int               `json:"rejected_count"`
+	RejectedRowsUrl string            `json:"rejected_rows_url"`
+	Errors          []models.RowError `json:"errors"`
+}
+
+func SubTestApplication_handleTransactionsUpload_201OnConflict(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+	onConflict string,
+	expected uploadResponseMock,
+) {
+	existing := testTransactions[0]
+	existing.PayeeName = "existing"
+	repo.models = []models.Transaction{existing, testTransactions[1]}
+	repo.imports = []models.Import{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+	c.Request.URL.RawQuery = "on_conflict=" + onConflict
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	actual := uploadResponseMock{}
+	err := json.Unmarshal(w.Body.Bytes(), &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual != expected {
+		t.Errorf("expected counts %+v, actual %+v", expected, actual)
+	}
+
+	if len(repo.models) != 3 {
+		t.Errorf("expected transactions count is %d, actual is %d", 3, len(repo.models))
+	}
+
+	expectedPayeeName := existing.PayeeName
+	if onConflict == "update" {
+		expectedPayeeName = testTransactions[0].PayeeName
+	}
+
+	if repo.models[0].PayeeName != expectedPayeeName {
+		t.Errorf("expected payee name %q, actual %q", expectedPayeeName, repo.models[0].PayeeName)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_400OnConflictError(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{testTransactions[1]}
+	repo.imports = []models.Import{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_400InvalidOnConflict(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+	c.Request.URL.RawQuery = "on_conflict=replace"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_422UnknownColumnsRejected(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{testData[0] + ",Comment", testData[1] + ",first"})
+	c.Request.URL.RawQuery = "unknown_columns=reject"
+
+	app.handleTransactionsUpload(c)
+
+	uploadAssertRowErrors(
+		t,
+		w,
+		[]models.RowError{{Line: 1, Reason: "unknown columns: Comment"}},
+		false,
+	)
+}
+
+func SubTestApplication_handleTransactionsUpload_422MissingColumns(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(
+		[]string{
+			strings.TrimSuffix(testData[0], ",PayeeBankAccount,PaymentNarrative"),
+			"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751",
+		},
+	)
+
+	app.handleTransactionsUpload(c)
+
+	uploadAssertRowErrors(
+		t,
+		w,
+		[]models.RowError{{Line: 1, Reason: "missing required columns: PayeeBankAccount, PaymentNarrative"}},
+		false,
+	)
+}
+
 func SubTestApplication_handleTransactionsUpload_400NotMultipartRequest(t *testing.T, app *Application) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -114,20 +572,131 @@ func SubTestApplication_handleTransactionsUpload_400MissingFile(t *testing.T, ap
 	}
 }
 
-func SubTestApplication_handleTransactionsUpload_400InvalidCSVData(t *testing.T, app *Application) {
+func SubTestApplication_handleTransactionsUpload_422InvalidCSVData(t *testing.T, app *Application) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request, _ = uploadTestRequestMock([]string{testData[0], "1,2,3"})
 
 	app.handleTransactionsUpload(c)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected status %d, actual %d", http.StatusCreated, w.Code)
+	uploadAssertRowErrors(t, w, []models.RowError{{Line: 2, Reason: "wrong number of fields"}}, false)
+}
+
+func SubTestApplication_handleTransactionsUpload_422MultipleInvalidRows(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(
+		[]string{
+			testData[0],
+			"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів",
+			"2,20030,x,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 12:36:52,2022-08-12 15:36:53,rejected,cash,PS16698215,13990,Поповнення карток,14332255,privat,255752,UA713461333619513,Перерахування коштів",
+			"3,20040,3508,1111,3.00,3.00,0.00,0.00,-0.01,2022-08-17 09:53:43,2022-08-17 12:53:44,accepted,card,PS16698225,14000,Поповнення карток,14432355,privat,256753,UA713471293319503,Перерахування коштів",
+			"4,20050,3509,1111,1.00,1.00,0.00,0.00,0.00,2022-13-17 09:53:43,2022-08-17 12:53:44,accepted,card,PS16698235,14000,Поповнення карток,14432355,privat,256753,UA713471293319503,Перерахування коштів",
+		},
+	)
+
+	app.handleTransactionsUpload(c)
+
+	uploadAssertRowErrors(
+		t,
+		w,
+		[]models.RowError{
+			{
+				Line:   3,
+				Column: "TerminalId",
+				Value:  "x",
+				Reason: "strconv.ParseUint: parsing \"x\": invalid syntax",
+			},
+			{
+				Line:   3,
+				Column: "Status",
+				Value:  "rejected",
+				Reason: "invalid status, expected accepted or declined, received rejected",
+			},
+			{
+				Line:   5,
+				Column: "DateInput",
+				Value:  "2022-13-17 09:53:43",
+				Reason: "parsing time \"2022-13-17 09:53:43\": month out of range",
+			},
+		},
+		false,
+	)
+
+	if len(repo.models) != 0 {
+		t.Errorf("expected no transactions to be saved, actual count is %d", len(repo.models))
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_422ErrorsTruncated(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{testData[0], "1,2,3", "4,5,6", "7,8,9"})
+	maxUploadErrors := app.MaxUploadErrors
+	app.MaxUploadErrors = 2
+	defer func() {
+		app.MaxUploadErrors = maxUploadErrors
+	}()
+
+	app.handleTransactionsUpload(c)
+
+	uploadAssertRowErrors(
+		t,
+		w,
+		[]models.RowError{
+			{Line: 2, Reason: "wrong number of fields"},
+			{Line: 3, Reason: "wrong number of fields"},
+		},
+		true,
+	)
+}
+
+func uploadAssertRowErrors(
+	t *testing.T,
+	w *httptest.ResponseRecorder,
+	expectedErrors []models.RowError,
+	expectedTruncated bool,
+) {
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, actual %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	response := invalidFileDataResponseMock{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedMessage := "invalid file data"
+	if response.Message != expectedMessage {
+		t.Errorf("expected message %q, actual %q", expectedMessage, response.Message)
+	}
+
+	if !reflect.DeepEqual(response.Errors, expectedErrors) {
+		t.Errorf("expected errors:\n%+v\nactual errors:\n%+v", expectedErrors, response.Errors)
+	}
+
+	if response.ErrorsTruncated != expectedTruncated {
+		t.Errorf("expected errors_truncated %v, actual %v", expectedTruncated, response.ErrorsTruncated)
 	}
 }
 
+type invalidFileDataResponseMock struct {
+	Message         string            `json:"message"`
+	Errors          []models.RowError `json:"errors"`
+	ErrorsTruncated bool              `json:"errors_truncated"`
+}
+
 type uploadResponseMock struct {
-	RowsCount int `json:"row_count"`
+	RowsCount     int `json:"row_count"`
+	InsertedCount int `json:"inserted_count"`
+	SkippedCount  int `json:"skipped_count"`
+	UpdatedCount  int `json:"updated_count"`
 }
 
 func uploadTestRequestMock(csv []string) (*http.Request, error) {
@@ -175,7 +744,14 @@ func init() {
 }
 
 type transactionRepositoryMock struct {
-	models []models.Transaction
+	models  []models.Transaction
+	imports []models.Import
+
+	// copyBatchCount is the number of CopyBatch calls
+	copyBatchCount int
+
+	// fields are passed to the last call of Filter or ForEach
+	fields []string
 }
 
 func newTransactionRepositoryMock(data []models.Transaction) *transactionRepositoryMock {
@@ -187,12 +763,57 @@ func (m *transactionRepositoryMock) Create(model models.Transaction) error {
 	return nil
 }
 
-func (m *transactionRepositoryMock) CreateBatch(models []models.Transaction) error {
+func (m *transactionRepositoryMock) CreateBatch(
+	models []models.Transaction,
+	onConflict repositories.ConflictStrategy,
+) (repositories.BatchResult, error) {
+	result := repositories.BatchResult{}
 	for _, model := range models {
-		_ = m.Create(model)
+		index := m.indexOf(model.Id)
+		switch {
+		case index == -1:
+			_ = m.Create(model)
+			result.Inserted++
+		case onConflict == repositories.ConflictSkip:
+			result.Skipped++
+		case onConflict == repositories.ConflictUpdate:
+			m.models[index] = model
+			result.Updated++
+		default:
+			return repositories.BatchResult{}, fmt.Errorf("duplicate key value: %d", model.Id)
+		}
 	}
 
-	return nil
+	return result, nil
+}
+
+func (m *transactionRepositoryMock) CopyBatch(
+	models []models.Transaction,
+	onConflict repositories.ConflictStrategy,
+) (repositories.BatchResult, error) {
+	m.copyBatchCount++
+	return m.CreateBatch(models, onConflict)
+}
+
+func (m *transactionRepositoryMock) FindExistingIds(ids []uint64) ([]uint64, error) {
+	existingIds := []uint64{}
+	for _, id := range ids {
+		if m.indexOf(id) != -1 {
+			existingIds = append(existingIds, id)
+		}
+	}
+
+	return existingIds, nil
+}
+
+func (m *transactionRepositoryMock) indexOf(id uint64) int {
+	for i, model := range m.models {
+		if model.Id == id {
+			return i
+		}
+	}
+
+	return -1
 }
 
 func (m *transactionRepositoryMock) UseTransaction(dbTransaction func(repositories.TransactionRepository) error) error {
@@ -201,15 +822,19 @@ func (m *transactionRepositoryMock) UseTransaction(dbTransaction func(repositori
 
 func (m *transactionRepositoryMock) Filter(
 	filters []repositories.TransactionFilter,
+	fields []string,
 	page, pageSize int,
 ) []models.Transaction {
+	m.fields = fields
 	return m.models
 }
 
 func (m *transactionRepositoryMock) ForEach(
 	filters []repositories.TransactionFilter,
+	fields []string,
 	apply func(model models.Transaction) error,
 ) error {
+	m.fields = fields
 	for _, model := range m.models {
 		err := apply(model)
 		if err != nil {
@@ -220,6 +845,10 @@ func (m *transactionRepositoryMock) ForEach(
 	return nil
 }
 
+func (m *transactionRepositoryMock) Imports() repositories.ImportRepository {
+	return &importRepositoryMock{transactions: m}
+}
+
 func (m *transactionRepositoryMock) NewFilterBuilder() repositories.TransactionFilterBuilder {
 	return &transactionFilterBuilderMock{}
 }
@@ -251,6 +880,10 @@ func (m *transactionFilterBuilderMock) AddPaymentNarrative(value string) error {
 	return nil
 }
 
+func (m *transactionFilterBuilderMock) AddImportId(value string) error {
+	return nil
+}
+
 func (m *transactionFilterBuilderMock) GetFilters() []repositories.TransactionFilter {
 	return []repositories.TransactionFilter{}
 }
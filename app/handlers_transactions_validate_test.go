@@ -0,0 +1,161 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleTransactionsValidate(t *testing.T) {
+	transactionRepository := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: transactionRepository,
+	}
+
+	t.Run(
+		"200Valid", func(t *testing.T) {
+			SubTestApplication_handleTransactionsValidate_200Valid(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"200InvalidRowsAndConflicts", func(t *testing.T) {
+			SubTestApplication_handleTransactionsValidate_200InvalidRowsAndConflicts(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"200InvalidHeader", func(t *testing.T) {
+			SubTestApplication_handleTransactionsValidate_200InvalidHeader(t, &app)
+		},
+	)
+	t.Run(
+		"400MissingFile", func(t *testing.T) {
+			SubTestApplication_handleTransactionsValidate_400MissingFile(t, &app)
+		},
+	)
+}
+
+func SubTestApplication_handleTransactionsValidate_200Valid(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+
+	app.handleTransactionsValidate(c)
+
+	validateAssertResponse(
+		t,
+		w,
+		validateResponseMock{
+			Valid:          true,
+			RowCount:       3,
+			Errors:         []models.RowError{},
+			ConflictingIds: []uint64{},
+		},
+	)
+
+	if len(repo.models) != 0 {
+		t.Errorf("expected no transactions to be saved, actual count is %d", len(repo.models))
+	}
+}
+
+func SubTestApplication_handleTransactionsValidate_200InvalidRowsAndConflicts(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{testTransactions[0], testTransactions[2]}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{testData[0], testData[1], "1,2,3", testData[2], testData[3]})
+
+	app.handleTransactionsValidate(c)
+
+	validateAssertResponse(
+		t,
+		w,
+		validateResponseMock{
+			RowCount:        4,
+			InvalidRowCount: 1,
+			Errors:          []models.RowError{{Line: 3, Reason: "wrong number of fields"}},
+			ConflictingIds:  []uint64{1, 3},
+		},
+	)
+
+	if len(repo.models) != 2 {
+		t.Errorf("expected transactions count is %d, actual is %d", 2, len(repo.models))
+	}
+}
+
+func SubTestApplication_handleTransactionsValidate_200InvalidHeader(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{"TransactionId,RequestId", "1,2"})
+
+	app.handleTransactionsValidate(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	response := validateResponseMock{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Valid {
+		t.Error("expected the file to be invalid")
+	}
+
+	if len(response.Errors) != 1 || response.Errors[0].Line != 1 {
+		t.Errorf("expected a single error in the header, actual %+v", response.Errors)
+	}
+}
+
+func SubTestApplication_handleTransactionsValidate_400MissingFile(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{})
+
+	app.handleTransactionsValidate(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func validateAssertResponse(t *testing.T, w *httptest.ResponseRecorder, expected validateResponseMock) {
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	actual := validateResponseMock{}
+	err := json.Unmarshal(w.Body.Bytes(), &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected response:\n%+v\nactual response:\n%+v", expected, actual)
+	}
+}
+
+type validateResponseMock struct {
+	Valid                   bool              `json:"valid"`
+	RowCount                int               `json:"row_count"`
+	InvalidRowCount         int               `json:"invalid_row_count"`
+	Errors                  []models.RowError `json:"errors"`
+	ErrorsTruncated         bool              `json:"errors_truncated"`
+	ConflictingIds          []uint64          `json:"conflicting_ids"`
+	ConflictingIdsTruncated bool              `json:"conflicting_ids_truncated"`
+}
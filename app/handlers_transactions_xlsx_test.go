@@ -0,0 +1,88 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleTransactionsAsXlsx_200(t *testing.T) {
+	repo := newTransactionRepositoryMock(testTransactions)
+	app := Application{
+		PageSize:              1,
+		TransactionRepository: repo,
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=TransactionId,PayeeBankAccount", nil)
+
+	app.handleTransactionsAsXlsx(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+	}
+
+	if w.Header().Get("Content-Type") != xlsxContentType {
+		t.Errorf("expected content type %s, actual %s", xlsxContentType, w.Header().Get("Content-Type"))
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sheet string
+	for _, file := range archive.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sheet = string(data)
+	}
+
+	// all transactions are returned regardless of the page size
+	for _, expected := range []string{`<c r="A4" s="1"><v>3</v></c>`, "UA713471293319503", `<autoFilter ref="A1:B4"/>`} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("expected %s in the sheet:\n%s", expected, sheet)
+		}
+	}
+
+	if strings.Contains(sheet, `r="C1"`) {
+		t.Errorf("expected 2 columns in the sheet:\n%s", sheet)
+	}
+}
+
+func TestApplication_handleTransactionsAsXlsx_400InvalidFields(t *testing.T) {
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: newTransactionRepositoryMock([]models.Transaction{testTransactions[0]}),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?fields=unknown", nil)
+
+	app.handleTransactionsAsXlsx(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
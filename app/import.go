@@ -0,0 +1,459 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"golang.org/x/text/encoding"
+)
+
+// errInvalidFileData is used to roll back the database transaction
+// when invalid rows are found in the imported file.
+var errInvalidFileData = errors.New("invalid file data")
+
+// duplicateFileError is returned when a file with the same content
+// was already imported and the import was not reverted.
+type duplicateFileError struct {
+	Previous *models.Import
+}
+
+func (e *duplicateFileError) Error() string {
+	return fmt.Sprintf("the file was already uploaded as import %d", e.Previous.Id)
+}
+
+// ImportOptions control how transactions are read from files and saved.
+type ImportOptions struct {
+	RejectUnknownColumns bool
+
+	// Partial enables saving of the valid rows when some rows are invalid.
+	// The invalid rows are written to a file with rejected rows.
+	Partial bool
+
+	// DryRun disables saving. Instead, ids of the valid rows are checked
+	// for conflicts with the existing transactions.
+	DryRun bool
+
+	// MaxErrors limits the number of collected row errors and conflicting ids.
+	// In the strict mode, the file is not read after the error limit is reached.
+	MaxErrors int
+
+	OnConflict repositories.ConflictStrategy
+
+	// Record describes the file being imported. It is saved together with
+	// the transactions, which refer to it, unless nothing is saved.
+	Record *models.Import
+
+	// Force allows importing a file which was already imported.
+	Force bool
+
+	// Encoding is the encoding of the data files, nil means UTF-8. It is
+	// ignored for files which start with a byte order mark or are UTF-16.
+	Encoding encoding.Encoding
+
+	// Dialect is the format of CSV files, the zero value means models.DefaultCsvDialect.
+	Dialect models.CsvDialect
+
+	// BatchSize is the number of transactions saved at once. If it is zero, the batches
+	// of MaxRowsPerDbCreateRequest rows grow to MaxRowsPerDbCopyRequest in large files.
+	BatchSize int
+
+	// Progress is called with the number of read rows after each
+	// MaxRowsPerDbCreateRequest rows and at the end of the file.
+	Progress func(rowsProcessed int)
+}
+
+// isStrict returns true if a single invalid row rejects the whole file.
+func (o ImportOptions) isStrict() bool {
+	return !o.Partial && !o.DryRun
+}
+
+// ImportResult contains the counts of the imported transactions and the row errors.
+type ImportResult struct {
+	// RowCount is the number of valid rows, see BatchResult for
+	// the numbers of inserted, skipped and updated transactions.
+	RowCount        int                   `json:"row_count"`
+	InsertedCount   int                   `json:"inserted_count"`
+	SkippedCount    int                   `json:"skipped_count"`
+	UpdatedCount    int                   `json:"updated_count"`
+	RejectedCount   int                   `json:"rejected_count"`
+	ImportId        uint64                `json:"import_id,omitempty"`
+	Files           []models.ImportedFile `json:"files,omitempty"`
+	RejectedRowsId  string                `json:"-"`
+	RejectedRowsUrl string                `json:"rejected_rows_url,omitempty"`
+	Errors          []models.RowError     `json:"errors,omitempty"`
+	ErrorsTruncated bool                  `json:"errors_truncated,omitempty"`
+
+	// ConflictingIds is collected in the dry run mode only.
+	ConflictingIds          []uint64 `json:"-"`
+	ConflictingIdsTruncated bool     `json:"-"`
+
+	// RejectedRowsPath is set for local files imported by ImportFile.
+	RejectedRowsPath string `json:"-"`
+}
+
+func (r *ImportResult) addBatchResult(batchResult repositories.BatchResult) {
+	r.InsertedCount += batchResult.Inserted
+	r.SkippedCount += batchResult.Skipped
+	r.UpdatedCount += batchResult.Updated
+	r.RowCount += batchResult.Inserted + batchResult.Skipped + batchResult.Updated
+}
+
+// addFileResult adds the counts of the file, which were changed since before.
+func (r *ImportResult) addFileResult(name string, before ImportResult) {
+	r.Files = append(
+		r.Files, models.ImportedFile{
+			Name:          name,
+			RowCount:      r.RowCount - before.RowCount,
+			InsertedCount: r.InsertedCount - before.InsertedCount,
+			SkippedCount:  r.SkippedCount - before.SkippedCount,
+			UpdatedCount:  r.UpdatedCount - before.UpdatedCount,
+			RejectedCount: r.RejectedCount - before.RejectedCount,
+		},
+	)
+}
+
+func (r *ImportResult) addErrors(rowErrors models.RowErrors, maxErrors int) {
+	for _, rowError := range rowErrors {
+		if len(r.Errors) == maxErrors {
+			r.ErrorsTruncated = true
+			return
+		}
+
+		r.Errors = append(r.Errors, rowError)
+	}
+}
+
+func (r *ImportResult) addConflictingIds(ids []uint64, maxIds int) {
+	for _, id := range ids {
+		if len(r.ConflictingIds) == maxIds {
+			r.ConflictingIdsTruncated = true
+			return
+		}
+
+		r.ConflictingIds = append(r.ConflictingIds, id)
+	}
+}
+
+// importTransactions reads transactions from the uploaded data and saves them to the
+// database in a single database transaction. If some rows are invalid, the
+// reading is continued to collect the errors of the other rows, but nothing
+// is saved in the strict mode. The returned error is not nil only if the data
+// cannot be read or saved for reasons other than invalid rows, or if ctx is
+// done before the import is finished.
+func (a *Application) importTransactions(
+	ctx context.Context,
+	data uploadedData,
+	options ImportOptions,
+) (ImportResult, error) {
+	result := ImportResult{}
+	if options.Dialect == (models.CsvDialect{}) {
+		options.Dialect = models.DefaultCsvDialect
+	}
+
+	if _, ok := data.ReadCloser.(io.ReadSeeker); ok && options.Record != nil && !options.DryRun && !options.Force {
+		// the file is checked again after the import, this check just prevents needless work
+		err := a.checkDuplicateFile(data)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	var rejectedRows *rejectedRowsFile
+	rejectRow := func(reader models.TransactionReader, rowErrors models.RowErrors) error {
+		result.RejectedCount++
+		result.addErrors(rowErrors, options.MaxErrors)
+		if !options.Partial {
+			return nil
+		}
+
+		if rejectedRows == nil {
+			var err error
+			rejectedRows, err = a.createRejectedRowsFile(reader.Header(), options.Dialect, options.Encoding)
+			if err != nil {
+				return err
+			}
+		}
+
+		return rejectedRows.Write(reader.Header(), reader.Record(), rowErrors)
+	}
+
+	rowsProcessed := 0
+	reportProgress := func() {
+		if options.Progress != nil {
+			options.Progress(rowsProcessed)
+		}
+	}
+
+	saveBatch := func(repository repositories.TransactionRepository, transactions []models.Transaction) error {
+		if options.DryRun {
+			ids := make([]uint64, len(transactions))
+			for i, transaction := range transactions {
+				ids[i] = transaction.Id
+			}
+
+			existingIds, err := repository.FindExistingIds(ids)
+			if err != nil {
+				return err
+			}
+
+			result.RowCount += len(transactions)
+			result.addConflictingIds(existingIds, options.MaxErrors)
+			return nil
+		}
+
+		saveTransactions := repository.CreateBatch
+		if len(transactions) > MaxRowsPerDbCreateRequest {
+			saveTransactions = repository.CopyBatch
+		}
+
+		batchResult, err := saveTransactions(transactions, options.OnConflict)
+		if err != nil {
+			return err
+		}
+
+		result.addBatchResult(batchResult)
+		return nil
+	}
+
+	checksum := sha256.New()
+	err := a.TransactionRepository.UseTransaction(
+		func(repository repositories.TransactionRepository) error {
+			record := options.Record
+			if options.DryRun {
+				record = nil
+			}
+
+			if record != nil {
+				record.StartedAt = time.Now()
+				err := repository.Imports().Create(record)
+				if err != nil {
+					return err
+				}
+			}
+
+			// the batch size is increased for large files to save them faster with COPY
+			batchSize := MaxRowsPerDbCreateRequest
+			if options.BatchSize > 0 {
+				batchSize = options.BatchSize
+			}
+			importFile := func(file dataFile) error {
+				// the checksum is calculated for the original content of the file
+				r := io.TeeReader(file, checksum)
+				reader := newTransactionReader(decodeText(r, options.Encoding), options.Dialect)
+				err := reader.ReadHeader(options.RejectUnknownColumns)
+				if err == io.EOF {
+					return nil
+				}
+
+				var rowErrors models.RowErrors
+				if errors.As(err, &rowErrors) {
+					// columns of the file are unknown, so its rows cannot be read
+					result.addErrors(setRowErrorsFile(rowErrors, file.Name), options.MaxErrors)
+					return errInvalidFileData
+				}
+
+				if err != nil {
+					return err
+				}
+
+				before := result
+				var transactions []models.Transaction
+				for !options.isStrict() || !result.ErrorsTruncated {
+					err := ctx.Err()
+					if err != nil {
+						return err
+					}
+
+					transaction, err := reader.Read()
+					if err == io.EOF {
+						break
+					}
+
+					rowsProcessed++
+					if rowsProcessed%MaxRowsPerDbCreateRequest == 0 {
+						reportProgress()
+					}
+
+					if errors.As(err, &rowErrors) {
+						err = rejectRow(reader, setRowErrorsFile(rowErrors, file.Name))
+						if err != nil {
+							return err
+						}
+
+						continue
+					}
+
+					if err != nil {
+						return err
+					}
+
+					if options.isStrict() && len(result.Errors) > 0 {
+						// nothing is saved if the file is invalid, just continue validation
+						continue
+					}
+
+					if record != nil {
+						transaction.ImportId = &record.Id
+					}
+
+					transactions = append(transactions, transaction)
+					if len(transactions) == batchSize {
+						err = saveBatch(repository, transactions)
+						if err != nil {
+							return err
+						}
+
+						transactions = []models.Transaction{}
+						if rowsProcessed >= MinRowsForDbCopyRequest && !options.DryRun && options.BatchSize == 0 {
+							batchSize = MaxRowsPerDbCopyRequest
+						}
+					}
+				}
+
+				if options.isStrict() && len(result.Errors) > 0 {
+					return nil
+				}
+
+				// the batch is saved to count the results of the file
+				if len(transactions) > 0 {
+					err := saveBatch(repository, transactions)
+					if err != nil {
+						return err
+					}
+				}
+
+				if file.Name != "" {
+					result.addFileResult(file.Name, before)
+				}
+
+				// the remaining data, if any, is read to calculate the checksum
+				_, err = io.Copy(io.Discard, r)
+				return err
+			}
+
+			err := a.forEachDataFile(data, importFile)
+			if err != nil {
+				return err
+			}
+
+			reportProgress()
+			if options.isStrict() && len(result.Errors) > 0 {
+				return errInvalidFileData
+			}
+
+			if record != nil {
+				err := saveImportRecord(repository.Imports(), record, result, checksum, options.Force)
+				if err != nil {
+					return err
+				}
+
+				result.ImportId = record.Id
+			}
+
+			if rejectedRows != nil {
+				return rejectedRows.Close()
+			}
+
+			return nil
+		},
+	)
+	if rejectedRows != nil {
+		if err == nil {
+			result.RejectedRowsId = rejectedRows.Id
+		} else {
+			_ = rejectedRows.Remove()
+		}
+	}
+
+	if err == errInvalidFileData {
+		// batches saved before the first invalid row are rolled back
+		return ImportResult{Errors: result.Errors, ErrorsTruncated: result.ErrorsTruncated}, nil
+	}
+
+	return result, err
+}
+
+// setRowErrorsFile sets the name of the file from an archive to the errors.
+func setRowErrorsFile(rowErrors models.RowErrors, name string) models.RowErrors {
+	for i := range rowErrors {
+		rowErrors[i].File = name
+	}
+
+	return rowErrors
+}
+
+// checkDuplicateFile calculates the checksum of the data and checks if it was
+// already imported. The data is rewound to the beginning after that.
+func (a *Application) checkDuplicateFile(data uploadedData) error {
+	seeker, ok := data.ReadCloser.(io.Seeker)
+	if !ok {
+		return nil
+	}
+
+	checksum := sha256.New()
+	err := a.forEachDataFile(
+		data, func(file dataFile) error {
+			_, err := io.Copy(checksum, file)
+			return err
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = seeker.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	return checkDuplicateChecksum(a.TransactionRepository.Imports(), hex.EncodeToString(checksum.Sum(nil)))
+}
+
+func checkDuplicateChecksum(repository repositories.ImportRepository, checksum string) error {
+	previous, err := repository.FindByChecksum(checksum)
+	if err == repositories.ErrNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return &duplicateFileError{Previous: previous}
+}
+
+// saveImportRecord saves the final counts of the import and the checksum of the
+// data. Unless force is true, duplicateFileError is returned for data imported before.
+func saveImportRecord(
+	repository repositories.ImportRepository,
+	record *models.Import,
+	result ImportResult,
+	checksum hash.Hash,
+	force bool,
+) error {
+	record.Checksum = hex.EncodeToString(checksum.Sum(nil))
+	if !force {
+		err := checkDuplicateChecksum(repository, record.Checksum)
+		if err != nil {
+			return err
+		}
+	}
+
+	finishedAt := time.Now()
+	record.FinishedAt = &finishedAt
+	record.RowCount = result.RowCount
+	record.InsertedCount = result.InsertedCount
+	record.SkippedCount = result.SkippedCount
+	record.UpdatedCount = result.UpdatedCount
+	record.RejectedCount = result.RejectedCount
+	record.Files = result.Files
+	return repository.Save(record)
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"TraineeGolangTestTask/models"
+)
+
+// ListImportFiles returns the files to be imported from the paths. Directories
+// are searched recursively for data files, gzip compressed data files and zip
+// archives, while files given explicitly are returned regardless of their names.
+func ListImportFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		// the directory is walked in the lexical order
+		err = filepath.WalkDir(
+			path, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if entry.Type().IsRegular() && isImportableFileName(entry.Name()) {
+					files = append(files, path)
+				}
+
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+func isImportableFileName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip":
+		return true
+	case ".gz":
+		return isDataFileName(strings.TrimSuffix(name, filepath.Ext(name)))
+	default:
+		return isDataFileName(name)
+	}
+}
+
+// ImportFile imports the local file the same way as an uploaded file is imported.
+// Unless it is a dry run, the import is recorded with the path as the file name and
+// the uploader from the options. If MaxErrors is zero, the configured limit is used.
+func (a *Application) ImportFile(ctx context.Context, path, uploader string, options ImportOptions) (ImportResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	defer file.Close()
+
+	if options.MaxErrors == 0 {
+		options.MaxErrors = a.getMaxUploadErrors()
+	}
+
+	options.Record = &models.Import{FileName: path, Uploader: uploader}
+	result, err := a.importTransactions(ctx, uploadedData{ReadCloser: file}, options)
+	if err != nil {
+		return result, err
+	}
+
+	if result.RejectedRowsId != "" {
+		result.RejectedRowsPath, err = a.getRejectedRowsFilePath(result.RejectedRowsId)
+	}
+
+	return result, err
+}
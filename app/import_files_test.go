@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+)
+
+func TestListImportFiles(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"a.csv", "b.txt", "nested/c.json.gz", "nested/d.zip", "nested/e.gz", "f.ndjson"}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(path, nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	explicitFile := filepath.Join(dir, "b.txt")
+	files, err := ListImportFiles([]string{dir, explicitFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a.csv"),
+		filepath.Join(dir, "f.ndjson"),
+		filepath.Join(dir, "nested/c.json.gz"),
+		filepath.Join(dir, "nested/d.zip"),
+		explicitFile,
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, actual %v", expected, files)
+	}
+
+	_, err = ListImportFiles([]string{filepath.Join(dir, "missing.csv")})
+	if err == nil {
+		t.Errorf("expected an error for the missing file")
+	}
+}
+
+func TestApplication_ImportFile(t *testing.T) {
+	repo := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{TransactionRepository: repo, DataDirectory: t.TempDir()}
+
+	t.Run("PartialMode", func(t *testing.T) { SubTestApplication_ImportFile_PartialMode(t, &app, repo) })
+	t.Run("DryRun", func(t *testing.T) { SubTestApplication_ImportFile_DryRun(t, &app, repo) })
+	t.Run("BatchSize", func(t *testing.T) { SubTestApplication_ImportFile_BatchSize(t, &app, repo) })
+}
+
+func SubTestApplication_ImportFile_PartialMode(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	err := os.WriteFile(path, []byte(strings.Join([]string{testData[0], testData[1], "1,2,3", testData[3]}, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := app.ImportFile(context.Background(), path, "ops", ImportOptions{Partial: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.RowCount != 2 || result.InsertedCount != 2 || result.RejectedCount != 1 {
+		t.Errorf("expected 2 rows inserted and 1 rejected, actual %+v", result)
+	}
+
+	if len(repo.imports) != 1 || repo.imports[0].FileName != path || repo.imports[0].Uploader != "ops" {
+		t.Errorf("expected the import of %s by ops, actual %+v", path, repo.imports)
+	}
+
+	if result.RejectedRowsPath == "" {
+		t.Fatalf("expected the path of rejected rows")
+	}
+
+	_, err = os.Stat(result.RejectedRowsPath)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func SubTestApplication_ImportFile_DryRun(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.models = []models.Transaction{testTransactions[0]}
+	repo.imports = []models.Import{}
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	err := os.WriteFile(path, []byte(strings.Join(testData, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := app.ImportFile(context.Background(), path, "ops", ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(result.ConflictingIds, []uint64{1}) {
+		t.Errorf("expected conflicting ids %v, actual %v", []uint64{1}, result.ConflictingIds)
+	}
+
+	if len(repo.models) != 1 || len(repo.imports) != 0 {
+		t.Errorf("expected nothing to be saved, actual %+v, %+v", repo.models, repo.imports)
+	}
+}
+
+func SubTestApplication_ImportFile_BatchSize(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	repo.copyBatchCount = 0
+
+	// the batches larger than MaxRowsPerDbCreateRequest are saved using COPY
+	batchSize := MaxRowsPerDbCreateRequest + 1
+	rowCount := 2 * batchSize
+	body := new(strings.Builder)
+	body.WriteString(testData[0])
+	fields := strings.SplitN(testData[1], ",", 2)
+	for i := 1; i <= rowCount; i++ {
+		body.WriteString(fmt.Sprintf("\n%d,%s", i, fields[1]))
+	}
+
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	err := os.WriteFile(path, []byte(body.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := app.ImportFile(context.Background(), path, "ops", ImportOptions{BatchSize: batchSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.InsertedCount != rowCount {
+		t.Errorf("expected inserted count %d, actual %d", rowCount, result.InsertedCount)
+	}
+
+	if repo.copyBatchCount != 2 {
+		t.Errorf("expected %d batches saved using COPY, actual %d", 2, repo.copyBatchCount)
+	}
+}
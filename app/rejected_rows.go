@@ -0,0 +1,241 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+const (
+	rejectedRowsDirectory = "rejected"
+
+	// rejectedRowsCleanupInterval is the period between removals of the expired files with rejected rows
+	rejectedRowsCleanupInterval = time.Hour
+)
+
+var randomIdRegexp = regexp.MustCompile("^[0-9a-f]{32}$")
+
+// rejectedRowsFile collects the rows rejected during a partial upload as CSV
+// data, so that the rows can be fixed and uploaded again.
+type rejectedRowsFile struct {
+	Id     string
+	header []string
+	file   *os.File
+
+	// encoder converts the rows to the encoding of the upload, it is nil for UTF-8
+	encoder io.WriteCloser
+	writer  *csv.Writer
+
+	// errorColumn is true if the errors are written in the additional Error column
+	errorColumn bool
+}
+
+// createRejectedRowsFile creates a file in the dialect and the encoding of the upload, so
+// that the fixed rows can be uploaded with the same options. The rows are written as they
+// were read, so the numbers and the dates keep the format of the dialect, and the rows of
+// JSON uploads are written in the columns of TransactionCsvHeader. If the dialect has
+// a header, it is written with the additional Error column describing the errors of
+// each row. Otherwise, the errors are only returned in the result of the upload, as
+// a headerless file cannot have more columns. The UTF-8 byte order mark is written if
+// the dialect enables it and textEncoding is nil.
+func (a *Application) createRejectedRowsFile(
+	header []string, dialect models.CsvDialect, textEncoding encoding.Encoding,
+) (*rejectedRowsFile, error) {
+	directory := filepath.Join(a.getDataDirectory(), rejectedRowsDirectory)
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newRandomId()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(filepath.Join(directory, id+".csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	rejectedRows := &rejectedRowsFile{Id: id, header: header, file: file, errorColumn: dialect.Header}
+	var output io.Writer = file
+	if textEncoding != nil {
+		rejectedRows.encoder = transform.NewWriter(file, encoding.ReplaceUnsupported(textEncoding.NewEncoder()))
+		output = rejectedRows.encoder
+	} else if dialect.Bom {
+		_, err = io.WriteString(file, models.Utf8Bom)
+		if err != nil {
+			_ = rejectedRows.Remove()
+			return nil, err
+		}
+	}
+
+	rejectedRows.writer = csv.NewWriter(output)
+	rejectedRows.writer.Comma = dialect.Delimiter
+	if dialect.Header {
+		err = rejectedRows.writer.Write(append(append([]string{}, header...), "Error"))
+		if err != nil {
+			_ = rejectedRows.Remove()
+			return nil, err
+		}
+	}
+
+	return rejectedRows, nil
+}
+
+// Write adds the record read from a file with the given header. Files of an archive
+// may have different columns, so their records are arranged by the header of the first
+// file with rejected rows. The fields of the columns missing in that header are dropped.
+func (f *rejectedRowsFile) Write(header, record []string, rowErrors models.RowErrors) error {
+	if !reflect.DeepEqual(header, f.header) {
+		record = rearrangeRecord(f.header, header, record)
+	}
+
+	if !f.errorColumn {
+		return f.writer.Write(record)
+	}
+
+	return f.writer.Write(append(append([]string{}, record...), rowErrors.Error()))
+}
+
+func rearrangeRecord(targetHeader, header, record []string) []string {
+	indices := make(map[string]int, len(header))
+	for i, column := range header {
+		indices[models.NormalizeCsvColumnName(column)] = i
+	}
+
+	rearranged := make([]string, len(targetHeader))
+	for i, column := range targetHeader {
+		index, ok := indices[models.NormalizeCsvColumnName(column)]
+		if ok && index < len(record) {
+			rearranged[i] = record[index]
+		}
+	}
+
+	return rearranged
+}
+
+func (f *rejectedRowsFile) Close() error {
+	f.writer.Flush()
+	err := f.writer.Error()
+	if err == nil && f.encoder != nil {
+		err = f.encoder.Close()
+	}
+
+	closeErr := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+func (f *rejectedRowsFile) Remove() error {
+	_ = f.file.Close()
+	return os.Remove(f.file.Name())
+}
+
+// getRejectedRowsFilePath returns the path of an existing file with rejected rows.
+// The expired files are not returned even if they are not removed yet.
+func (a *Application) getRejectedRowsFilePath(id string) (string, error) {
+	if !randomIdRegexp.MatchString(id) {
+		return "", os.ErrNotExist
+	}
+
+	path := filepath.Join(a.getDataDirectory(), rejectedRowsDirectory, id+".csv")
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if a.isRejectedRowsFileExpired(info, time.Now()) {
+		return "", os.ErrNotExist
+	}
+
+	return path, nil
+}
+
+// cleanUpRejectedRowsFiles removes the expired files with rejected rows at once,
+// then every rejectedRowsCleanupInterval until ctx is done.
+func (a *Application) cleanUpRejectedRowsFiles(ctx context.Context) {
+	ticker := time.NewTicker(rejectedRowsCleanupInterval)
+	defer ticker.Stop()
+	for {
+		removed, err := a.removeExpiredRejectedRowsFiles(time.Now())
+		if err != nil {
+			log.Printf("unable to remove expired files with rejected rows: %v\n", err)
+		} else if removed > 0 {
+			log.Printf("removed %d expired files with rejected rows\n", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeExpiredRejectedRowsFiles removes the files with rejected rows which were last
+// written earlier than the TTL before now and returns the number of removed files.
+func (a *Application) removeExpiredRejectedRowsFiles(now time.Time) (int, error) {
+	directory := filepath.Join(a.getDataDirectory(), rejectedRowsDirectory)
+	entries, err := os.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !randomIdRegexp.MatchString(strings.TrimSuffix(entry.Name(), ".csv")) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || !a.isRejectedRowsFileExpired(info, now) {
+			// the file may be removed meanwhile
+			continue
+		}
+
+		err = os.Remove(filepath.Join(directory, entry.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (a *Application) isRejectedRowsFileExpired(info os.FileInfo, now time.Time) bool {
+	return now.Sub(info.ModTime()) > a.getRejectedRowsTtl()
+}
+
+func newRandomId() (string, error) {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", errors.New("unable to generate a random identifier")
+	}
+
+	return hex.EncodeToString(data), nil
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestApplication_removeExpiredRejectedRowsFiles(t *testing.T) {
+	app := Application{DataDirectory: t.TempDir(), RejectedRowsTtl: time.Hour}
+	removed, err := app.removeExpiredRejectedRowsFiles(time.Now())
+	if err != nil || removed != 0 {
+		t.Fatalf("expected no files without the directory, actual %d %v", removed, err)
+	}
+
+	expired := createTestRejectedRowsFile(t, &app)
+	kept := createTestRejectedRowsFile(t, &app)
+	modifiedAt := time.Now().Add(-time.Hour - time.Minute)
+	err = os.Chtimes(filepath.Join(app.DataDirectory, rejectedRowsDirectory, expired+".csv"), modifiedAt, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other files in the directory are not removed
+	other := filepath.Join(app.DataDirectory, rejectedRowsDirectory, "other.csv")
+	err = os.WriteFile(other, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(other, modifiedAt, modifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = app.getRejectedRowsFilePath(expired)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected the expired file to be unavailable, actual %v", err)
+	}
+
+	removed, err = app.removeExpiredRejectedRowsFiles(time.Now())
+	if err != nil || removed != 1 {
+		t.Errorf("expected 1 removed file, actual %d %v", removed, err)
+	}
+
+	_, err = os.Stat(filepath.Join(app.DataDirectory, rejectedRowsDirectory, expired+".csv"))
+	if !os.IsNotExist(err) {
+		t.Errorf("expected the expired file to be removed, actual %v", err)
+	}
+
+	_, err = app.getRejectedRowsFilePath(kept)
+	if err != nil {
+		t.Errorf("expected the file to be kept, actual %v", err)
+	}
+
+	_, err = os.Stat(other)
+	if err != nil {
+		t.Errorf("expected the other file to be kept, actual %v", err)
+	}
+}
+
+// TestApplication_ImportFile_RejectedRowsReupload uploads the file with rejected rows
+// with the options of the original upload, and the same rows are rejected for the same reasons.
+func TestApplication_ImportFile_RejectedRowsReupload(t *testing.T) {
+	noHeaderDialect := models.DefaultCsvDialect
+	noHeaderDialect.Header = false
+	excelUaWithoutBom := models.CsvDialectPresets["excel-ua"]
+	excelUaWithoutBom.Bom = false
+
+	tests := []struct {
+		name         string
+		dialect      models.CsvDialect
+		textEncoding encoding.Encoding
+	}{
+		{"Default", models.DefaultCsvDialect, nil},
+		{"NoHeader", noHeaderDialect, nil},
+		{"ExcelUaWithBom", models.CsvDialectPresets["excel-ua"], nil},
+		{"Windows1251", excelUaWithoutBom, charmap.Windows1251},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				repo := newTransactionRepositoryMock([]models.Transaction{})
+				app := Application{TransactionRepository: repo, DataDirectory: t.TempDir()}
+				options := ImportOptions{Partial: true, Dialect: test.dialect, Encoding: test.textEncoding}
+
+				invalid := testTransactions[1]
+				// the value is not ASCII to check the encoding of the file
+				invalid.PaymentNumber = "ПС16698215"
+				path := writeTestCsvFile(t, test.dialect, test.textEncoding, testTransactions[0], invalid)
+				result, err := app.ImportFile(context.Background(), path, "ops", options)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if result.InsertedCount != 1 || result.RejectedCount != 1 || result.RejectedRowsPath == "" {
+					t.Fatalf("expected 1 row inserted and 1 rejected, actual %+v", result)
+				}
+
+				data, err := os.ReadFile(result.RejectedRowsPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				hasBom := bytes.HasPrefix(data, []byte(models.Utf8Bom))
+				if hasBom != (test.dialect.Bom && test.textEncoding == nil) {
+					t.Errorf("expected the byte order mark %v, actual %v", !hasBom, hasBom)
+				}
+
+				reupload, err := app.ImportFile(context.Background(), result.RejectedRowsPath, "ops", options)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if reupload.RowCount != 0 || reupload.RejectedCount != 1 || len(reupload.Errors) != 1 {
+					t.Fatalf("expected the row to be rejected again, actual %+v", reupload)
+				}
+
+				expected, actual := result.Errors[0], reupload.Errors[0]
+				if actual.Column != expected.Column || actual.Value != expected.Value || actual.Reason != expected.Reason {
+					t.Errorf("expected %+v, actual %+v", expected, actual)
+				}
+			},
+		)
+	}
+}
+
+// writeTestCsvFile writes the transactions to a file in the dialect and the encoding.
+func writeTestCsvFile(
+	t *testing.T, dialect models.CsvDialect, textEncoding encoding.Encoding, transactions ...models.Transaction,
+) string {
+	buffer := &bytes.Buffer{}
+	writer := models.NewTransactionCsvDialectWriter(buffer, dialect)
+	err := writer.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transaction := range transactions {
+		err = writer.Write(transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buffer.Bytes()
+	if textEncoding != nil {
+		data, err = textEncoding.NewEncoder().Bytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func createTestRejectedRowsFile(t *testing.T, app *Application) string {
+	rejectedRows, err := app.createRejectedRowsFile(models.TransactionCsvHeader, models.DefaultCsvDialect, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rejectedRows.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rejectedRows.Id
+}
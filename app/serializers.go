@@ -0,0 +1,300 @@
+package app
+
+import (
+	"net/http"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+// TransactionSerializer sends the filtered transactions in one format. The format
+// of the /api/transactions endpoint is selected either by the "format" parameter,
+// which is matched against Format, or by the Accept header, which is matched
+// against ContentType.
+type TransactionSerializer interface {
+	Format() string
+	ContentType() string
+
+	// Serialize sends the transactions matching the filters with the columns of the
+	// projection. The parameters specific to the format are parsed by it as well.
+	Serialize(c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection)
+}
+
+// transactionSerializers contains the registered serializers. The first one
+// is used if the client accepts any format or does not specify it.
+var transactionSerializers []TransactionSerializer
+
+func init() {
+	RegisterTransactionSerializer(jsonTransactionSerializer{})
+	RegisterTransactionSerializer(csvTransactionSerializer{})
+	RegisterTransactionSerializer(ndjsonTransactionSerializer{})
+	RegisterTransactionSerializer(xlsxTransactionSerializer{})
+	RegisterTransactionSerializer(parquetTransactionSerializer{})
+}
+
+// RegisterTransactionSerializer adds the format to the /api/transactions endpoint, or replaces
+// the serializer of the format if it is already registered. It should be called before
+// the server is started.
+func RegisterTransactionSerializer(serializer TransactionSerializer) {
+	for i, registered := range transactionSerializers {
+		if registered.Format() == serializer.Format() {
+			transactionSerializers[i] = serializer
+			return
+		}
+	}
+
+	transactionSerializers = append(transactionSerializers, serializer)
+}
+
+// findTransactionSerializer returns the serializer of the format or nil if it is not registered.
+func findTransactionSerializer(format string) TransactionSerializer {
+	for _, serializer := range transactionSerializers {
+		if serializer.Format() == format {
+			return serializer
+		}
+	}
+
+	return nil
+}
+
+func getTransactionFormats() []string {
+	formats := make([]string, len(transactionSerializers))
+	for i, serializer := range transactionSerializers {
+		formats[i] = serializer.Format()
+	}
+
+	return formats
+}
+
+// jsonTransactionSerializer sends a page of transactions as JSON.
+type jsonTransactionSerializer struct{}
+
+func (jsonTransactionSerializer) Format() string {
+	return "json"
+}
+
+func (jsonTransactionSerializer) ContentType() string {
+	return gin.MIMEJSON
+}
+
+func (jsonTransactionSerializer) Serialize(
+	c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) {
+	page, ok := a.parsePageParameter(c)
+	if !ok {
+		return
+	}
+
+	transactions := a.TransactionRepository.Filter(filters, projection.FieldNames(), page, a.PageSize)
+	a.BankDirectory.SetPayeeBankNames(transactions)
+	a.sendPage(c, page, len(transactions), projection.Apply(transactions))
+}
+
+// csvTransactionSerializer streams all transactions as CSV in the dialect given by the parameters.
+type csvTransactionSerializer struct{}
+
+func (csvTransactionSerializer) Format() string {
+	return "csv"
+}
+
+func (csvTransactionSerializer) ContentType() string {
+	return "text/csv"
+}
+
+func (s csvTransactionSerializer) Serialize(
+	c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) {
+	dialect, err := parseCsvDialectParameters(c.DefaultQuery)
+	if err != nil {
+		a.sendBadRequest(c, err.Error())
+		return
+	}
+
+	flusher := startTransactionsStream(c, s.ContentType(), "")
+	csvWriter := models.NewTransactionCsvDialectWriter(c.Writer, dialect)
+	csvWriter.SetProjection(projection)
+	err = csvWriter.WriteHeader()
+	if err != nil {
+		// return from the handler to trigger closing the connection
+		return
+	}
+
+	err = a.TransactionRepository.ForEach(
+		filters, projection.FieldNames(), func(model models.Transaction) error {
+			err := csvWriter.Write(model)
+			if err != nil {
+				return err
+			}
+
+			err = csvWriter.Flush()
+			if err != nil {
+				return err
+			}
+
+			flusher.Flush()
+			return nil
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	if csvWriter.Flush() != nil {
+		return
+	}
+
+	flusher.Flush()
+}
+
+// ndjsonTransactionSerializer streams all transactions as NDJSON. The response is
+// flushed after every exportFlushRows transactions, so that a chunk is not sent per line.
+type ndjsonTransactionSerializer struct{}
+
+func (ndjsonTransactionSerializer) Format() string {
+	return "ndjson"
+}
+
+func (ndjsonTransactionSerializer) ContentType() string {
+	return ndjsonContentType
+}
+
+func (s ndjsonTransactionSerializer) Serialize(
+	c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) {
+	flusher := startTransactionsStream(c, s.ContentType(), "")
+	ndjsonWriter := models.NewTransactionNdjsonWriter(c.Writer)
+	ndjsonWriter.SetProjection(projection)
+	rowCount := 0
+	err := a.TransactionRepository.ForEach(
+		filters, projection.FieldNames(), func(model models.Transaction) error {
+			model.PayeeBankName = a.BankDirectory.Name(model.PayeeBankMfo)
+			err := ndjsonWriter.Write(model)
+			if err != nil {
+				return err
+			}
+
+			rowCount++
+			if rowCount%exportFlushRows != 0 {
+				return nil
+			}
+
+			err = ndjsonWriter.Flush()
+			if err != nil {
+				return err
+			}
+
+			flusher.Flush()
+			return nil
+		},
+	)
+	if err != nil {
+		// return from the handler to trigger closing the connection
+		return
+	}
+
+	if ndjsonWriter.Flush() != nil {
+		return
+	}
+
+	flusher.Flush()
+}
+
+// xlsxTransactionSerializer streams all transactions as an Excel workbook.
+// The response is flushed after every exportFlushRows transactions.
+type xlsxTransactionSerializer struct{}
+
+func (xlsxTransactionSerializer) Format() string {
+	return "xlsx"
+}
+
+func (xlsxTransactionSerializer) ContentType() string {
+	return xlsxContentType
+}
+
+func (s xlsxTransactionSerializer) Serialize(
+	c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) {
+	flusher := startTransactionsStream(c, s.ContentType(), "transactions.xlsx")
+	xlsxWriter := models.NewTransactionXlsxWriter(c.Writer)
+	xlsxWriter.SetProjection(projection)
+	err := xlsxWriter.WriteHeader()
+	if err != nil {
+		// return from the handler to trigger closing the connection
+		return
+	}
+
+	rowCount := 0
+	err = a.TransactionRepository.ForEach(
+		filters, projection.FieldNames(), func(model models.Transaction) error {
+			err := xlsxWriter.Write(model)
+			if err != nil {
+				return err
+			}
+
+			rowCount++
+			if rowCount%exportFlushRows != 0 {
+				return nil
+			}
+
+			err = xlsxWriter.Flush()
+			if err != nil {
+				return err
+			}
+
+			flusher.Flush()
+			return nil
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	if xlsxWriter.Close() != nil {
+		return
+	}
+
+	flusher.Flush()
+}
+
+// parquetTransactionSerializer sends all transactions as a Parquet file.
+// The row groups are sent as they are written, the footer is sent last.
+type parquetTransactionSerializer struct{}
+
+func (parquetTransactionSerializer) Format() string {
+	return "parquet"
+}
+
+func (parquetTransactionSerializer) ContentType() string {
+	return parquetContentType
+}
+
+func (s parquetTransactionSerializer) Serialize(
+	c *gin.Context, a *Application, filters []repositories.TransactionFilter, projection models.TransactionProjection,
+) {
+	flusher := startTransactionsStream(c, s.ContentType(), "transactions.parquet")
+	err := a.ExportTransactionsAsParquet(c.Writer, filters, projection)
+	if err != nil {
+		// return from the handler to trigger closing the connection
+		return
+	}
+
+	flusher.Flush()
+}
+
+// startTransactionsStream sends the headers of the chunked response, so that the
+// body is sent as it is written. The body is sent as an attachment if filename is set.
+func startTransactionsStream(c *gin.Context, contentType, filename string) http.Flusher {
+	writer := c.Writer
+	header := writer.Header()
+	header.Set("Transfer-Encoding", "chunked")
+	header.Set("Content-Type", contentType)
+	if filename != "" {
+		header.Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+
+	writer.WriteHeader(http.StatusOK)
+	flusher := writer.(http.Flusher)
+	flusher.Flush()
+	return flusher
+}
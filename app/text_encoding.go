@@ -0,0 +1,63 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"TraineeGolangTestTask/models"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var (
+	utf16LeBom = []byte{0xff, 0xfe}
+	utf16BeBom = []byte{0xfe, 0xff}
+)
+
+// GetTextEncoding returns the encoding by one of its names or aliases, such as
+// windows-1251, cp1251 or koi8-u. Nil is returned for UTF-8 and for an empty name.
+func GetTextEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	textEncoding, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	if textEncoding == unicode.UTF8 {
+		return nil, nil
+	}
+
+	return textEncoding, nil
+}
+
+// decodeText converts the data to UTF-8. A byte order mark takes precedence over
+// textEncoding. UTF-16 data without the mark is recognized by a zero byte in the
+// first character, because both CSV headers and JSON data start with ASCII.
+func decodeText(r io.Reader, textEncoding encoding.Encoding) io.Reader {
+	reader := bufio.NewReader(r)
+
+	// an error means there is not enough data, which is checked by the prefixes
+	start, _ := reader.Peek(len(models.Utf8Bom))
+	switch {
+	case bytes.HasPrefix(start, []byte(models.Utf8Bom)):
+		_, _ = reader.Discard(len(models.Utf8Bom))
+		return reader
+	case bytes.HasPrefix(start, utf16LeBom), len(start) >= 2 && start[0] != 0 && start[1] == 0:
+		textEncoding = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case bytes.HasPrefix(start, utf16BeBom), len(start) >= 2 && start[0] == 0 && start[1] != 0:
+		textEncoding = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	}
+
+	if textEncoding == nil {
+		return reader
+	}
+
+	return transform.NewReader(reader, textEncoding.NewDecoder())
+}
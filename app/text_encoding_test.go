@@ -0,0 +1,211 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func Test_decodeText(t *testing.T) {
+	text := "PayeeName,Service\nОлійник,Поповнення\n"
+	utf16Le, _ := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().String(text)
+	utf16Be, _ := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder().String(text)
+	windows1251, _ := charmap.Windows1251.NewEncoder().String(text)
+	koi8u, _ := charmap.KOI8U.NewEncoder().String(text)
+	tests := []struct {
+		name         string
+		data         string
+		textEncoding encoding.Encoding
+	}{
+		{"Utf8", text, nil},
+		{"Utf8Bom", models.Utf8Bom + text, nil},
+		{"Utf8BomWithEncoding", models.Utf8Bom + text, charmap.Windows1251},
+		{"Utf16LeBom", string(utf16LeBom) + utf16Le, nil},
+		{"Utf16BeBom", string(utf16BeBom) + utf16Be, nil},
+		{"Utf16LeWithoutBom", utf16Le, nil},
+		{"Utf16BeWithoutBom", utf16Be, nil},
+		{"Windows1251", windows1251, charmap.Windows1251},
+		{"Koi8u", koi8u, charmap.KOI8U},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				decoded, err := io.ReadAll(decodeText(strings.NewReader(test.data), test.textEncoding))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(decoded) != text {
+					t.Errorf("expected %q, actual %q", text, decoded)
+				}
+			},
+		)
+	}
+}
+
+func TestGetTextEncoding(t *testing.T) {
+	for name, expected := range map[string]encoding.Encoding{
+		"":             nil,
+		"utf-8":        nil,
+		"windows-1251": charmap.Windows1251,
+		"CP1251":       charmap.Windows1251,
+		"koi8-u":       charmap.KOI8U,
+	} {
+		actual, err := GetTextEncoding(name)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", name, err)
+		}
+
+		if actual != expected {
+			t.Errorf("expected %v for %q, actual %v", expected, name, actual)
+		}
+	}
+
+	_, err := GetTextEncoding("unknown")
+	if err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+}
+
+func TestApplication_handleTransactionsUpload_encoding(t *testing.T) {
+	transactionRepository := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: transactionRepository,
+	}
+
+	t.Run(
+		"201Windows1251", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201Windows1251(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"422InvalidUtf8", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_422InvalidUtf8(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"400UnsupportedEncoding", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_400UnsupportedEncoding(t, &app, transactionRepository)
+		},
+	)
+}
+
+func SubTestApplication_handleTransactionsUpload_201Windows1251(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	data, err := charmap.Windows1251.NewEncoder().String(strings.Join(testData, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/?encoding=windows-1251", strings.NewReader(data))
+	c.Request.Header.Set("Content-Type", "text/csv")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.models) != len(testTransactions) {
+		t.Fatalf("expected transactions count is %d, actual is %d", len(testTransactions), len(repo.models))
+	}
+
+	for i, transaction := range testTransactions {
+		if repo.models[i].PaymentNarrative != transaction.PaymentNarrative {
+			t.Errorf("expected narrative %q, actual %q", transaction.PaymentNarrative, repo.models[i].PaymentNarrative)
+		}
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_422InvalidUtf8(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	data, err := charmap.Windows1251.NewEncoder().String(testData[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the encoding is not specified, so the second row is not valid UTF-8
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(
+		http.MethodPost,
+		"/",
+		bytes.NewReader([]byte(strings.Join([]string{testData[0], testData[1], data}, "\n"))),
+	)
+	c.Request.Header.Set("Content-Type", "text/csv")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+
+	response := struct {
+		Errors []models.RowError `json:"errors"`
+	}{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedColumns := []string{"Service", "PaymentNarrative"}
+	if len(response.Errors) != len(expectedColumns) {
+		t.Fatalf("expected errors of columns %v, actual %+v", expectedColumns, response.Errors)
+	}
+
+	for i, rowError := range response.Errors {
+		if rowError.Line != 3 || rowError.Column != expectedColumns[i] {
+			t.Errorf("expected an error of %s in line 3, actual %+v", expectedColumns[i], rowError)
+		}
+	}
+
+	if len(repo.models) != 0 {
+		t.Errorf("expected no transactions, actual %d", len(repo.models))
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_400UnsupportedEncoding(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/?encoding=ebcdic", strings.NewReader(strings.Join(testData, "\n")))
+	c.Request.Header.Set("Content-Type", "text/csv")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+
+	if len(repo.models) != 0 {
+		t.Errorf("expected no transactions, actual %d", len(repo.models))
+	}
+}
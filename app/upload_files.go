@@ -0,0 +1,241 @@
+package app
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"TraineeGolangTestTask/models"
+)
+
+type uploadFormat int
+
+const (
+	uploadFormatPlain uploadFormat = iota
+	uploadFormatGzip
+	uploadFormatZip
+)
+
+var (
+	gzipMagicBytes = []byte{0x1f, 0x8b}
+	zipMagicBytes  = []byte("PK\x03\x04")
+)
+
+var errNoDataFiles = errors.New("the archive does not contain CSV or JSON files")
+
+// dataFileExtensions are extensions of the files which are read from zip archives.
+var dataFileExtensions = map[string]bool{".csv": true, ".json": true, ".ndjson": true, ".jsonl": true}
+
+// uploadedData is an uploaded data file, a data file compressed with gzip or
+// a zip archive with data files. A data file contains transactions either
+// as CSV data, as a JSON array or as NDJSON data.
+type uploadedData struct {
+	io.ReadCloser
+
+	// ContentType is used to detect the format of the data. If it is not
+	// specific to a format, the beginning of the data is checked instead.
+	ContentType string
+}
+
+// dataFile is a file with transactions read from the uploaded data.
+type dataFile struct {
+	io.Reader
+
+	// Name is set only for files from a zip archive.
+	Name string
+}
+
+// forEachDataFile decompresses the data if required and calls read for each data file
+// in it. Files from an archive are read in the order they are stored in the archive.
+func (a *Application) forEachDataFile(data uploadedData, read func(file dataFile) error) error {
+	reader := bufio.NewReader(data)
+	switch detectUploadFormat(data.ContentType, reader) {
+	case uploadFormatGzip:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("invalid gzip data: %w", err)
+		}
+
+		defer gzipReader.Close()
+		return read(dataFile{Reader: gzipReader})
+	case uploadFormatZip:
+		return a.forEachZipEntry(data, reader, read)
+	default:
+		return read(dataFile{Reader: reader})
+	}
+}
+
+func detectUploadFormat(contentType string, reader *bufio.Reader) uploadFormat {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/gzip", "application/x-gzip":
+		return uploadFormatGzip
+	case "application/zip", "application/x-zip-compressed":
+		return uploadFormatZip
+	}
+
+	// an error means there is not enough data, which is treated as uncompressed data
+	header, _ := reader.Peek(len(zipMagicBytes))
+	switch {
+	case bytes.HasPrefix(header, gzipMagicBytes):
+		return uploadFormatGzip
+	case bytes.HasPrefix(header, zipMagicBytes):
+		return uploadFormatZip
+	default:
+		return uploadFormatPlain
+	}
+}
+
+// forEachZipEntry reads the data files of the archive, other files are ignored.
+// The archive is read randomly, so the data which is not an io.ReaderAt is
+// stored to a temporary file first.
+func (a *Application) forEachZipEntry(data uploadedData, reader io.Reader, read func(file dataFile) error) error {
+	archive, size, err := getZipReaderAt(data.ReadCloser)
+	if err != nil {
+		return err
+	}
+
+	if archive == nil {
+		file, err := a.storeTemporaryUploadFile(reader)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}()
+
+		archive, size, err = getZipReaderAt(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	zipReader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	found := false
+	for _, entry := range zipReader.File {
+		if entry.FileInfo().IsDir() || !isDataFileName(entry.Name) {
+			continue
+		}
+
+		found = true
+		err = readZipEntry(entry, read)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return errNoDataFiles
+	}
+
+	return nil
+}
+
+func readZipEntry(entry *zip.File, read func(file dataFile) error) error {
+	reader, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("unable to read %s from the zip archive: %w", entry.Name, err)
+	}
+
+	defer reader.Close()
+	return read(dataFile{Reader: reader, Name: entry.Name})
+}
+
+// getZipReaderAt returns a nil reader if r does not support random access.
+func getZipReaderAt(r io.Reader) (io.ReaderAt, int64, error) {
+	readerAt, ok := r.(io.ReaderAt)
+	if !ok {
+		return nil, 0, nil
+	}
+
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, 0, nil
+	}
+
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return readerAt, size, nil
+}
+
+// isDataFileName ignores metadata stored by macOS in archives.
+func isDataFileName(name string) bool {
+	return dataFileExtensions[strings.ToLower(path.Ext(name))] &&
+		!strings.HasPrefix(name, "__MACOSX/") &&
+		!strings.HasPrefix(path.Base(name), "._")
+}
+
+// newTransactionReader detects the format of the data file by its first
+// character: JSON arrays and NDJSON objects are recognized, other data is
+// read as CSV in the dialect.
+func newTransactionReader(r io.Reader, dialect models.CsvDialect) models.TransactionReader {
+	reader := bufio.NewReader(r)
+	switch peekFirstCharacter(reader) {
+	case '[':
+		return models.NewTransactionJsonReader(reader, true)
+	case '{':
+		return models.NewTransactionJsonReader(reader, false)
+	default:
+		return models.NewTransactionCsvDialectReader(reader, dialect)
+	}
+}
+
+// peekFirstCharacter returns the first character which is not a white space,
+// zero is returned if there is no such character in the buffer of the reader.
+func peekFirstCharacter(reader *bufio.Reader) byte {
+	for n := 1; n <= reader.Size(); n++ {
+		data, err := reader.Peek(n)
+		if err != nil {
+			return 0
+		}
+
+		switch character := data[n-1]; character {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return character
+		}
+	}
+
+	return 0
+}
+
+func (a *Application) storeTemporaryUploadFile(r io.Reader) (*os.File, error) {
+	directory := filepath.Join(a.getDataDirectory(), uploadJobsDirectory)
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(directory, "*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,406 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_handleTransactionsUpload_compressed(t *testing.T) {
+	transactionRepository := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: transactionRepository,
+	}
+
+	t.Run(
+		"201GzipFile", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201GzipFile(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201ZipArchive", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201ZipArchive(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201ZipBody", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201ZipBody(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"400ZipWithoutCsvFiles", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_400ZipWithoutCsvFiles(t, &app, transactionRepository)
+		},
+	)
+}
+
+func SubTestApplication_handleTransactionsUpload_201GzipFile(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	data := new(bytes.Buffer)
+	writer := gzip.NewWriter(data)
+	_, _ = writer.Write([]byte(strings.Join(testData, "\n")))
+	_ = writer.Close()
+
+	// the format is detected by the content
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = compressedUploadTestRequestMock(t, "file.csv.gz", data.Bytes())
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.models) != len(testTransactions) {
+		t.Errorf("expected transactions count is %d, actual is %d", len(testTransactions), len(repo.models))
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201ZipArchive(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	app.DataDirectory = t.TempDir()
+	defer func() {
+		app.DataDirectory = ""
+	}()
+
+	invalidRows := []string{
+		strings.Replace(testData[2], "2,", "x,", 1),
+		strings.Replace(testData[3], "3,", "y,", 1),
+	}
+	archive := zipTestArchiveMock(
+		t,
+		"first.csv", strings.Join([]string{testData[0], testData[1], invalidRows[0]}, "\n"),
+		"readme.txt", "ignored",
+		"daily/second.csv", strings.Join(reverseTestColumns(testData[0], testData[3], invalidRows[1]), "\n"),
+	)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = compressedUploadTestRequestMock(t, "archive.zip", archive)
+	c.Request.URL.RawQuery = "mode=partial"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	response := struct {
+		RowCount        int                   `json:"row_count"`
+		RejectedCount   int                   `json:"rejected_count"`
+		RejectedRowsUrl string                `json:"rejected_rows_url"`
+		Files           []models.ImportedFile `json:"files"`
+		Errors          []models.RowError     `json:"errors"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.RowCount != 2 || response.RejectedCount != 2 {
+		t.Errorf("expected 2 rows and 2 rejected rows, actual %d and %d", response.RowCount, response.RejectedCount)
+	}
+
+	expectedFiles := []models.ImportedFile{
+		{Name: "first.csv", RowCount: 1, InsertedCount: 1, RejectedCount: 1},
+		{Name: "daily/second.csv", RowCount: 1, InsertedCount: 1, RejectedCount: 1},
+	}
+	if len(response.Files) != len(expectedFiles) {
+		t.Fatalf("expected files %+v, actual %+v", expectedFiles, response.Files)
+	}
+
+	for i, file := range response.Files {
+		if file != expectedFiles[i] {
+			t.Errorf("expected file %+v, actual %+v", expectedFiles[i], file)
+		}
+	}
+
+	if len(response.Errors) != 2 || response.Errors[1].File != "daily/second.csv" || response.Errors[1].Line != 3 {
+		t.Errorf("expected the second error in line 3 of daily/second.csv, actual %+v", response.Errors)
+	}
+
+	if len(repo.imports) != 1 || len(repo.imports[0].Files) != len(expectedFiles) {
+		t.Errorf("expected a single import with %d files, actual %+v", len(expectedFiles), repo.imports)
+	}
+
+	// the rejected rows are arranged by the columns of the first file
+	path, err := app.getRejectedRowsFilePath(strings.TrimPrefix(response.RejectedRowsUrl, "/api/transactions/rejected/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := readCsvTestFile(t, path)
+	if len(records) != len(invalidRows)+1 {
+		t.Fatalf("expected %d records, actual %q", len(invalidRows)+1, records)
+	}
+
+	for i, invalidRow := range invalidRows {
+		expectedRecord := strings.Split(invalidRow, ",")
+		if !reflect.DeepEqual(records[i+1][:len(expectedRecord)], expectedRecord) {
+			t.Errorf("expected rejected row %q, actual %q", expectedRecord, records[i+1])
+		}
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201ZipBody(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	app.DataDirectory = t.TempDir()
+	defer func() {
+		app.DataDirectory = ""
+	}()
+
+	archive := zipTestArchiveMock(
+		t,
+		"first.csv", strings.Join(testData[:2], "\n"),
+		"second.csv", strings.Join([]string{testData[0], testData[2], testData[3]}, "\n"),
+	)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(archive))
+	c.Request.Header.Set("Content-Type", "application/zip")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.models) != len(testTransactions) {
+		t.Errorf("expected transactions count is %d, actual is %d", len(testTransactions), len(repo.models))
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_400ZipWithoutCsvFiles(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = compressedUploadTestRequestMock(t, "archive.zip", zipTestArchiveMock(t, "readme.txt", "nothing"))
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+
+	if len(repo.imports) != 0 {
+		t.Errorf("expected no imports, actual %+v", repo.imports)
+	}
+}
+
+func TestApplication_handleTransactionsUpload_json(t *testing.T) {
+	transactionRepository := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{
+		PageSize:              5,
+		TransactionRepository: transactionRepository,
+	}
+
+	t.Run(
+		"201JsonBody", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201JsonBody(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"201NdjsonFile", func(t *testing.T) {
+			SubTestApplication_handleTransactionsUpload_201NdjsonFile(t, &app, transactionRepository)
+		},
+	)
+}
+
+func SubTestApplication_handleTransactionsUpload_201JsonBody(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	data, err := json.Marshal(testTransactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	if len(repo.models) != len(testTransactions) {
+		t.Fatalf("expected transactions count is %d, actual is %d", len(testTransactions), len(repo.models))
+	}
+
+	for i, transaction := range testTransactions {
+		if repo.models[i].Id != transaction.Id || !repo.models[i].DatePost.Equal(transaction.DatePost) {
+			t.Errorf("expected transaction %+v, actual %+v", transaction, repo.models[i])
+		}
+	}
+}
+
+func SubTestApplication_handleTransactionsUpload_201NdjsonFile(
+	t *testing.T,
+	app *Application,
+	repo *transactionRepositoryMock,
+) {
+	repo.models = []models.Transaction{}
+	repo.imports = []models.Import{}
+	app.DataDirectory = t.TempDir()
+	defer func() {
+		app.DataDirectory = ""
+	}()
+
+	lines := make([]string, len(testTransactions))
+	for i, transaction := range testTransactions {
+		data, err := json.Marshal(transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines[i] = string(data)
+	}
+
+	lines[1] = strings.Replace(lines[1], `"status":"`, `"status":"x`, 1)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = compressedUploadTestRequestMock(t, "transactions.ndjson", []byte(strings.Join(lines, "\n")))
+	c.Request.URL.RawQuery = "mode=partial"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	response := struct {
+		RejectedCount int               `json:"rejected_count"`
+		Errors        []models.RowError `json:"errors"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.RejectedCount != 1 || len(response.Errors) != 1 {
+		t.Fatalf("expected a single rejected transaction, actual %+v", response)
+	}
+
+	if response.Errors[0].Line != 2 || response.Errors[0].Column != "status" {
+		t.Errorf("expected an error of status in line 2, actual %+v", response.Errors[0])
+	}
+
+	if len(repo.models) != len(testTransactions)-1 {
+		t.Errorf("expected transactions count is %d, actual is %d", len(testTransactions)-1, len(repo.models))
+	}
+}
+
+// compressedUploadTestRequestMock sends the data as a file of the generic type.
+func compressedUploadTestRequestMock(t *testing.T, filename string, data []byte) *http.Request {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	fileWriter, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fileWriter.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestMock := httptest.NewRequest(http.MethodPost, "/", body)
+	requestMock.Header.Set("Content-Type", writer.FormDataContentType())
+	return requestMock
+}
+
+// zipTestArchiveMock creates an archive of files given as pairs of names and contents.
+func zipTestArchiveMock(t *testing.T, files ...string) []byte {
+	data := new(bytes.Buffer)
+	writer := zip.NewWriter(data)
+	for i := 0; i < len(files); i += 2 {
+		fileWriter, err := writer.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = fileWriter.Write([]byte(files[i+1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data.Bytes()
+}
+
+func reverseTestColumns(rows ...string) []string {
+	reversed := make([]string, len(rows))
+	for i, row := range rows {
+		fields := strings.Split(row, ",")
+		for left, right := 0, len(fields)-1; left < right; left, right = left+1, right-1 {
+			fields[left], fields[right] = fields[right], fields[left]
+		}
+
+		reversed[i] = strings.Join(fields, ",")
+	}
+
+	return reversed
+}
+
+func readCsvTestFile(t *testing.T, path string) [][]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
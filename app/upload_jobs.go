@@ -0,0 +1,316 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+)
+
+const (
+	uploadJobsDirectory = "uploads"
+	uploadJobQueueSize  = 1000
+)
+
+var errUploadQueueFull = errors.New("too many uploads are waiting for processing, try again later")
+
+// uploadWorkerPool processes upload jobs in the background. Ids of the queued
+// jobs are sent to the queue, while the jobs themselves are stored in the database.
+type uploadWorkerPool struct {
+	queue chan string
+
+	// stopping is closed to prevent workers from taking new jobs
+	stopping chan struct{}
+
+	// ctx is cancelled to interrupt the running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startUploadWorkers starts the given number of workers and resumes the jobs
+// left after the previous run: queued jobs are processed again, and jobs which
+// were running when the server was stopped unexpectedly are marked as interrupted.
+// Queued jobs whose files cannot be found are marked as failed.
+func (a *Application) startUploadWorkers(count int) error {
+	jobs, err := a.UploadJobRepository.FindByStates(models.UploadJobQueued, models.UploadJobRunning)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &uploadWorkerPool{
+		queue:    make(chan string, uploadJobQueueSize),
+		stopping: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	a.uploadWorkers = pool
+	for _, job := range jobs {
+		if job.State == models.UploadJobRunning {
+			job.State = models.UploadJobInterrupted
+			job.Message = "the server was stopped while the file was being processed"
+			a.finishUploadJob(&job)
+			continue
+		}
+
+		_, err = os.Stat(a.getUploadJobFilePath(job.Id))
+		if err != nil {
+			job.State = models.UploadJobFailed
+			job.Message = err.Error()
+			a.finishUploadJob(&job)
+			continue
+		}
+
+		if !a.enqueueUploadJob(job.Id) {
+			log.Printf("unable to resume upload job %s: %v\n", job.Id, errUploadQueueFull)
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		pool.wg.Add(1)
+		go a.runUploadWorker(pool)
+	}
+
+	return nil
+}
+
+// stopUploadWorkers waits for the running jobs until ctx is done, then the
+// remaining jobs are interrupted and queued again to be resumed on the next start.
+func (a *Application) stopUploadWorkers(ctx context.Context) {
+	pool := a.uploadWorkers
+	if pool == nil {
+		return
+	}
+
+	close(pool.stopping)
+	done := make(chan struct{})
+	go func() {
+		pool.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		pool.cancel()
+		<-done
+	}
+
+	pool.cancel()
+}
+
+func (a *Application) enqueueUploadJob(id string) bool {
+	if a.uploadWorkers == nil {
+		return false
+	}
+
+	select {
+	case a.uploadWorkers.queue <- id:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *Application) runUploadWorker(pool *uploadWorkerPool) {
+	defer pool.wg.Done()
+	for {
+		select {
+		case <-pool.stopping:
+			return
+		case id := <-pool.queue:
+			select {
+			case <-pool.stopping:
+				// the job stays queued in the database
+				return
+			default:
+				a.runUploadJob(pool.ctx, id)
+			}
+		}
+	}
+}
+
+// createUploadJob stores the uploaded file to be processed by upload workers.
+// The file is stored as is, its format is detected by the content type of
+// the job and by the data when it is processed.
+func (a *Application) createUploadJob(r io.Reader, job *models.UploadJob) error {
+	id, err := newRandomId()
+	if err != nil {
+		return err
+	}
+
+	job.Id = id
+	job.State = models.UploadJobQueued
+	err = a.storeUploadJobFile(id, r)
+	if err != nil {
+		return err
+	}
+
+	err = a.UploadJobRepository.Create(job)
+	if err != nil {
+		_ = os.Remove(a.getUploadJobFilePath(id))
+		return err
+	}
+
+	if !a.enqueueUploadJob(id) {
+		job.State = models.UploadJobFailed
+		job.Message = errUploadQueueFull.Error()
+		a.finishUploadJob(job)
+		return errUploadQueueFull
+	}
+
+	return nil
+}
+
+func (a *Application) runUploadJob(ctx context.Context, id string) {
+	job, err := a.UploadJobRepository.FindById(id)
+	if err != nil {
+		log.Printf("unable to load upload job %s: %v\n", id, err)
+		return
+	}
+
+	startedAt := time.Now()
+	job.State = models.UploadJobRunning
+	job.StartedAt = &startedAt
+	err = a.UploadJobRepository.Save(job)
+	if err != nil {
+		log.Printf("unable to start upload job %s: %v\n", id, err)
+		return
+	}
+
+	result, err := a.processUploadJob(ctx, job)
+	if err != nil && ctx.Err() != nil {
+		// the database transaction is rolled back, so the job can be run again
+		job.State = models.UploadJobQueued
+		job.StartedAt = nil
+		job.RowsProcessed = 0
+		err = a.UploadJobRepository.Save(job)
+		if err != nil {
+			log.Printf("unable to requeue upload job %s: %v\n", id, err)
+		}
+
+		return
+	}
+
+	switch {
+	case err != nil:
+		job.State = models.UploadJobFailed
+		job.Message = err.Error()
+	case len(result.Errors) > 0 && (!job.Partial || result.RejectedCount == 0):
+		job.State = models.UploadJobFailed
+		job.Message = errInvalidFileData.Error()
+	default:
+		job.State = models.UploadJobCompleted
+	}
+
+	job.RowCount = result.RowCount
+	job.InsertedCount = result.InsertedCount
+	job.SkippedCount = result.SkippedCount
+	job.UpdatedCount = result.UpdatedCount
+	job.RejectedCount = result.RejectedCount
+	job.RejectedRowsId = result.RejectedRowsId
+	if result.ImportId != 0 {
+		job.ImportId = &result.ImportId
+	}
+
+	job.Files = result.Files
+	job.Errors = result.Errors
+	job.ErrorsTruncated = result.ErrorsTruncated
+	a.finishUploadJob(job)
+}
+
+func (a *Application) processUploadJob(ctx context.Context, job *models.UploadJob) (ImportResult, error) {
+	onConflict, err := repositories.ParseConflictStrategy(job.OnConflict)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	textEncoding, err := GetTextEncoding(job.Encoding)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	file, err := os.Open(a.getUploadJobFilePath(job.Id))
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	defer file.Close()
+
+	return a.importTransactions(
+		ctx,
+		uploadedData{ReadCloser: file, ContentType: job.ContentType},
+		ImportOptions{
+			RejectUnknownColumns: job.RejectUnknownColumns,
+			Partial:              job.Partial,
+			MaxErrors:            a.getMaxUploadErrors(),
+			OnConflict:           onConflict,
+			Record:               &models.Import{FileName: job.FileName, Uploader: job.Uploader},
+			Force:                job.Force,
+			Encoding:             textEncoding,
+			Dialect:              job.CsvDialect,
+			Progress: func(rowsProcessed int) {
+				err := a.UploadJobRepository.UpdateProgress(job.Id, rowsProcessed)
+				if err != nil {
+					log.Printf("unable to update progress of upload job %s: %v\n", job.Id, err)
+				}
+
+				job.RowsProcessed = rowsProcessed
+			},
+		},
+	)
+}
+
+// finishUploadJob saves the final state of the job and removes the stored file.
+func (a *Application) finishUploadJob(job *models.UploadJob) {
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	err := a.UploadJobRepository.Save(job)
+	if err != nil {
+		log.Printf("unable to save upload job %s: %v\n", job.Id, err)
+	}
+
+	err = os.Remove(a.getUploadJobFilePath(job.Id))
+	if err != nil && !os.IsNotExist(err) {
+		log.Println(err)
+	}
+}
+
+func (a *Application) storeUploadJobFile(id string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Join(a.getDataDirectory(), uploadJobsDirectory), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(a.getUploadJobFilePath(id))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+
+	return nil
+}
+
+// getUploadJobFilePath returns the path of the stored file, which has no extension,
+// as the format of the file does not depend on its name.
+func (a *Application) getUploadJobFilePath(id string) string {
+	return filepath.Join(a.getDataDirectory(), uploadJobsDirectory, id)
+}
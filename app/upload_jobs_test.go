@@ -0,0 +1,313 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplication_uploadJobs(t *testing.T) {
+	transactionRepository := newTransactionRepositoryMock([]models.Transaction{})
+	uploadJobRepository := newUploadJobRepositoryMock()
+	app := Application{
+		DataDirectory:         t.TempDir(),
+		UploadWorkers:         1,
+		TransactionRepository: transactionRepository,
+		UploadJobRepository:   uploadJobRepository,
+	}
+
+	uploadJobRepository.jobs["queued"] = &models.UploadJob{Id: "queued", State: models.UploadJobQueued, OnConflict: "skip"}
+	uploadJobRepository.jobs["running"] = &models.UploadJob{Id: "running", State: models.UploadJobRunning}
+	uploadJobRepository.jobs["queuedNdjson"] = &models.UploadJob{
+		Id:          "queuedNdjson",
+		State:       models.UploadJobQueued,
+		ContentType: ndjsonContentType,
+		OnConflict:  "skip",
+	}
+
+	// the file of the job is not stored
+	uploadJobRepository.jobs["missing"] = &models.UploadJob{Id: "missing", State: models.UploadJobQueued}
+
+	for _, id := range []string{"queued", "running"} {
+		err := app.storeUploadJobFile(id, strings.NewReader(strings.Join(testData[:2], "\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := json.Marshal(testTransactions[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.storeUploadJobFile("queuedNdjson", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.startUploadWorkers(app.getUploadWorkers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer app.stopUploadWorkers(context.Background())
+
+	t.Run(
+		"Resume", func(t *testing.T) {
+			SubTestApplication_uploadJobs_Resume(t, &app)
+		},
+	)
+	t.Run(
+		"202", func(t *testing.T) {
+			SubTestApplication_uploadJobs_202(t, &app, transactionRepository)
+		},
+	)
+	t.Run(
+		"FailedInvalidFileData", func(t *testing.T) {
+			SubTestApplication_uploadJobs_FailedInvalidFileData(t, &app)
+		},
+	)
+	t.Run(
+		"400InvalidAsync", func(t *testing.T) {
+			SubTestApplication_uploadJobs_400InvalidAsync(t, &app)
+		},
+	)
+	t.Run(
+		"404", func(t *testing.T) {
+			SubTestApplication_uploadJobs_404(t, &app)
+		},
+	)
+}
+
+func SubTestApplication_uploadJobs_Resume(t *testing.T, app *Application) {
+	job := uploadJobsWaitForJob(t, app, "queued")
+	if job.State != models.UploadJobCompleted || job.InsertedCount != 1 {
+		t.Errorf("expected the queued job to be completed with 1 inserted row, actual %+v", job)
+	}
+
+	job = uploadJobsWaitForJob(t, app, "queuedNdjson")
+	if job.State != models.UploadJobCompleted || job.InsertedCount != 1 {
+		t.Errorf("expected the queued NDJSON job to be completed with 1 inserted row, actual %+v", job)
+	}
+
+	job = uploadJobsWaitForJob(t, app, "running")
+	if job.State != models.UploadJobInterrupted {
+		t.Errorf("expected state %q, actual %q", models.UploadJobInterrupted, job.State)
+	}
+
+	job = uploadJobsWaitForJob(t, app, "missing")
+	if job.State != models.UploadJobFailed || !strings.Contains(job.Message, "no such file") {
+		t.Errorf("expected the job to fail without the file, actual %q: %s", job.State, job.Message)
+	}
+
+	for _, id := range []string{"queued", "queuedNdjson", "running"} {
+		_, err := os.Stat(app.getUploadJobFilePath(id))
+		if !os.IsNotExist(err) {
+			t.Errorf("expected the file of job %s to be removed", id)
+		}
+	}
+}
+
+func SubTestApplication_uploadJobs_202(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.models = []models.Transaction{}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+	c.Request.URL.RawQuery = "async=true"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, actual %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	response := struct {
+		Id        string `json:"id"`
+		StatusUrl string `json:"status_url"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedStatusUrl := "/api/uploads/" + response.Id
+	if response.StatusUrl != expectedStatusUrl {
+		t.Errorf("expected status url %q, actual %q", expectedStatusUrl, response.StatusUrl)
+	}
+
+	job := uploadJobsWaitForJob(t, app, response.Id)
+	if job.State != models.UploadJobCompleted {
+		t.Fatalf("expected state %q, actual %q: %s", models.UploadJobCompleted, job.State, job.Message)
+	}
+
+	expectedCount := len(testTransactions)
+	if job.RowsProcessed != expectedCount || job.InsertedCount != expectedCount {
+		t.Errorf(
+			"expected %d processed and inserted rows, actual %d and %d",
+			expectedCount,
+			job.RowsProcessed,
+			job.InsertedCount,
+		)
+	}
+
+	if job.FileName != "file.csv" {
+		t.Errorf("expected file name %q, actual %q", "file.csv", job.FileName)
+	}
+
+	if len(repo.models) != expectedCount {
+		t.Errorf("expected transactions count is %d, actual is %d", expectedCount, len(repo.models))
+	}
+}
+
+func SubTestApplication_uploadJobs_FailedInvalidFileData(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock([]string{testData[0], "1,2,3"})
+	c.Request.URL.RawQuery = "async=1"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, actual %d", http.StatusAccepted, w.Code)
+	}
+
+	response := struct {
+		Id string `json:"id"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := uploadJobsWaitForJob(t, app, response.Id)
+	if job.State != models.UploadJobFailed {
+		t.Errorf("expected state %q, actual %q", models.UploadJobFailed, job.State)
+	}
+
+	if len(job.Errors) != 1 || job.Errors[0].Line != 2 {
+		t.Errorf("expected a single error in line 2, actual %+v", job.Errors)
+	}
+}
+
+func SubTestApplication_uploadJobs_400InvalidAsync(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = uploadTestRequestMock(testData)
+	c.Request.URL.RawQuery = "async=later"
+
+	app.handleTransactionsUpload(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, actual %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func SubTestApplication_uploadJobs_404(t *testing.T, app *Application) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "unknown"}}
+
+	app.handleUploadJob(c)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, actual %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// uploadJobsWaitForJob polls the status endpoint until the job is finished.
+func uploadJobsWaitForJob(t *testing.T, app *Application, id string) models.UploadJob {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: id}}
+
+		app.handleUploadJob(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, actual %d", http.StatusOK, w.Code)
+		}
+
+		job := models.UploadJob{}
+		err := json.Unmarshal(w.Body.Bytes(), &job)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if job.FinishedAt != nil {
+			return job
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is not finished, state %q", id, job.State)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type uploadJobRepositoryMock struct {
+	mutex sync.Mutex
+	jobs  map[string]*models.UploadJob
+}
+
+func newUploadJobRepositoryMock() *uploadJobRepositoryMock {
+	return &uploadJobRepositoryMock{jobs: map[string]*models.UploadJob{}}
+}
+
+func (m *uploadJobRepositoryMock) Create(job *models.UploadJob) error {
+	return m.Save(job)
+}
+
+func (m *uploadJobRepositoryMock) Save(job *models.UploadJob) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	saved := *job
+	m.jobs[job.Id] = &saved
+	return nil
+}
+
+func (m *uploadJobRepositoryMock) UpdateProgress(id string, rowsProcessed int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs[id].RowsProcessed = rowsProcessed
+	return nil
+}
+
+func (m *uploadJobRepositoryMock) FindById(id string) (*models.UploadJob, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, repositories.ErrNotFound
+	}
+
+	found := *job
+	return &found, nil
+}
+
+func (m *uploadJobRepositoryMock) FindByStates(states ...models.UploadJobState) ([]models.UploadJob, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var jobs []models.UploadJob
+	for _, job := range m.jobs {
+		for _, state := range states {
+			if job.State == state {
+				jobs = append(jobs, *job)
+			}
+		}
+	}
+
+	return jobs, nil
+}
@@ -1,12 +1,17 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"TraineeGolangTestTask/models"
 	"TraineeGolangTestTask/repositories"
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/postgres"
@@ -68,30 +73,243 @@ func getEnvOrDefault(key, default_ string) string {
 }
 
 func parseParameters(c *gin.Context, builder repositories.TransactionFilterBuilder) error {
-	err := builder.AddTransactionId(c.DefaultQuery("transaction_id", ""))
+	return addFilterParameters(builder, c.DefaultQuery, c.QueryArray)
+}
+
+// addFilterParameters adds the filters given by the parameters to the builder. The parameters
+// are read by getParameter and getParameters, so that both the query parameters of a request
+// and the url.Values built from the command line can be parsed.
+func addFilterParameters(
+	builder repositories.TransactionFilterBuilder,
+	getParameter func(key, defaultValue string) string,
+	getParameters func(key string) []string,
+) error {
+	err := builder.AddTransactionId(getParameter("transaction_id", ""))
+	if err != nil {
+		return err
+	}
+
+	err = builder.AddTerminalIds(getParameters("terminal_id"))
 	if err != nil {
 		return err
 	}
 
-	err = builder.AddTerminalIds(c.QueryArray("terminal_id"))
+	err = builder.AddStatus(getParameter("status", ""))
 	if err != nil {
 		return err
 	}
 
-	err = builder.AddStatus(c.DefaultQuery("status", ""))
+	err = builder.AddPaymentType(getParameter("payment_type", ""))
 	if err != nil {
 		return err
 	}
 
-	err = builder.AddPaymentType(c.DefaultQuery("payment_type", ""))
+	err = builder.AddDatePostRange(getParameter("date_post_from", ""), getParameter("date_post_to", ""))
 	if err != nil {
 		return err
 	}
 
-	err = builder.AddDatePostRange(c.DefaultQuery("date_post_from", ""), c.DefaultQuery("date_post_to", ""))
+	err = builder.AddPaymentNarrative(getParameter("payment_narrative", ""))
 	if err != nil {
 		return err
 	}
 
-	return builder.AddPaymentNarrative(c.DefaultQuery("payment_narrative", ""))
+	return builder.AddImportId(getParameter("import_id", ""))
+}
+
+// acceptsNdjson returns true if the client prefers NDJSON to JSON by the Accept header.
+func acceptsNdjson(c *gin.Context) bool {
+	return negotiateContentType(c.GetHeader("Accept"), []string{gin.MIMEJSON, ndjsonContentType}) == ndjsonContentType
+}
+
+// negotiateContentType returns the offered content type which is preferred by the Accept header.
+// The type with the greatest quality is preferred, then the type matched by the range which is
+// listed earlier in the header, then the type offered earlier. The first offered type is returned
+// if the header is empty, and the empty string is returned if none of the types is acceptable.
+func negotiateContentType(accept string, offered []string) string {
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+
+	var ranges []mediaRange
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	if len(ranges) == 0 {
+		return offered[0]
+	}
+
+	best, bestQuality, bestRange := "", 0.0, 0
+	for _, contentType := range offered {
+		// the most specific range matching the type defines its quality
+		matched, specificity := -1, -1
+		for i, candidate := range ranges {
+			rangeSpecificity := -1
+			switch {
+			case candidate.mediaType == contentType:
+				rangeSpecificity = 2
+			case candidate.mediaType == "*/*":
+				rangeSpecificity = 0
+			case strings.HasSuffix(candidate.mediaType, "/*") &&
+				strings.HasPrefix(contentType, strings.TrimSuffix(candidate.mediaType, "*")):
+				rangeSpecificity = 1
+			}
+
+			if rangeSpecificity > specificity {
+				matched, specificity = i, rangeSpecificity
+			}
+		}
+
+		if matched < 0 || ranges[matched].quality <= 0 {
+			continue
+		}
+
+		quality := ranges[matched].quality
+		if quality > bestQuality || quality == bestQuality && matched < bestRange {
+			best, bestQuality, bestRange = contentType, quality, matched
+		}
+	}
+
+	return best
+}
+
+// getUploadParameter returns the value of the upload option from the query
+// string or, if it is absent there, from the multipart form.
+func getUploadParameter(c *gin.Context, key, defaultValue string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+
+	return defaultValue
+}
+
+// getUploadParameters returns a function which gets upload options by getUploadParameter.
+func getUploadParameters(c *gin.Context) func(key, defaultValue string) string {
+	return func(key, defaultValue string) string {
+		return getUploadParameter(c, key, defaultValue)
+	}
+}
+
+func parseUnknownColumnsParameter(c *gin.Context) (bool, error) {
+	switch value := getUploadParameter(c, "unknown_columns", "ignore"); value {
+	case "ignore":
+		return false, nil
+	case "reject":
+		return true, nil
+	default:
+		return false, errors.New("value of \"unknown_columns\" parameter should be either \"ignore\" or \"reject\"")
+	}
+}
+
+// parseUploadModeParameter returns true if valid rows should be saved
+// even when some rows of the uploaded file are invalid.
+func parseUploadModeParameter(c *gin.Context) (bool, error) {
+	switch value := getUploadParameter(c, "mode", "strict"); value {
+	case "strict":
+		return false, nil
+	case "partial":
+		return true, nil
+	default:
+		return false, errors.New("value of \"mode\" parameter should be either \"strict\" or \"partial\"")
+	}
+}
+
+// parseBoolUploadParameter parses the upload option which is false by default.
+func parseBoolUploadParameter(c *gin.Context, key string) (bool, error) {
+	value, err := strconv.ParseBool(getUploadParameter(c, key, "false"))
+	if err != nil {
+		return false, fmt.Errorf("value of \"%s\" parameter should be a boolean", key)
+	}
+
+	return value, nil
+}
+
+// parseCsvDialectParameters returns the preset given by the "dialect" parameter with
+// the other dialect options applied to it. The parameters are read by getParameter,
+// so that both upload options and query parameters of the export can be parsed.
+func parseCsvDialectParameters(getParameter func(key, defaultValue string) string) (models.CsvDialect, error) {
+	presetName := getParameter("dialect", "default")
+	dialect, ok := models.CsvDialectPresets[presetName]
+	if !ok {
+		return dialect, fmt.Errorf("unknown CSV dialect %q", presetName)
+	}
+
+	if value := getParameter("delimiter", ""); value != "" {
+		delimiter, err := parseCharacterParameter("delimiter", value)
+		if err != nil {
+			return dialect, err
+		}
+
+		dialect.Delimiter = delimiter
+	}
+
+	if value := getParameter("decimal_separator", ""); value != "" {
+		separator, err := parseCharacterParameter("decimal_separator", value)
+		if err != nil {
+			return dialect, err
+		}
+
+		dialect.DecimalSeparator = separator
+	}
+
+	if value := getParameter("date_layout", ""); value != "" {
+		dialect.TimeLayout = value
+	}
+
+	for key, option := range map[string]*bool{"header": &dialect.Header, "bom": &dialect.Bom} {
+		if value := getParameter(key, ""); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return dialect, fmt.Errorf("value of \"%s\" parameter should be a boolean", key)
+			}
+
+			*option = enabled
+		}
+	}
+
+	err := dialect.Validate()
+	if err != nil {
+		return dialect, fmt.Errorf("invalid CSV dialect: %v", err)
+	}
+
+	return dialect, nil
+}
+
+// parseCharacterParameter accepts a single character or "tab".
+func parseCharacterParameter(key, value string) (rune, error) {
+	if value == "tab" {
+		return '\t', nil
+	}
+
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("value of \"%s\" parameter should be a single character", key)
+	}
+
+	character, _ := utf8.DecodeRuneInString(value)
+	return character, nil
+}
+
+// getUploader returns the name of the user who uploads the file, which is
+// sent either as the "uploader" parameter or in the X-Uploader header.
+func getUploader(c *gin.Context) string {
+	return getUploadParameter(c, "uploader", c.GetHeader("X-Uploader"))
 }
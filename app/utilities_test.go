@@ -88,6 +88,10 @@ func SubTest_parseParameters_Parsed(t *testing.T, c *gin.Context) {
 			Key:   "payment_narrative",
 			Value: "some text",
 		},
+		{
+			Key:   "import_id",
+			Value: "3",
+		},
 	}
 	builder := TransactionFilterBuilderMock{Filters: map[filterHash]string{}}
 	_ = parseParameters(c, &builder)
@@ -115,6 +119,10 @@ func SubTest_parseParameters_Parsed(t *testing.T, c *gin.Context) {
 	if !builder.hasFilterWithValue(paymentNarrativeFilter, c.DefaultQuery("payment_narrative", "")) {
 		t.Errorf("filter %s is absent or has incorrect value", "payment_narrative")
 	}
+
+	if !builder.hasFilterWithValue(importIdFilter, c.DefaultQuery("import_id", "")) {
+		t.Errorf("filter %s is absent or has incorrect value", "import_id")
+	}
 }
 
 func SubTest_parseParameters_FailedDueToBuilderError(t *testing.T, c *gin.Context) {
@@ -134,6 +142,7 @@ const (
 	paymentTypeFilter
 	datePostRangeFilter
 	paymentNarrativeFilter
+	importIdFilter
 )
 
 type TransactionFilterBuilderMock struct {
@@ -170,6 +179,11 @@ func (tf *TransactionFilterBuilderMock) AddPaymentNarrative(value string) error
 	return nil
 }
 
+func (tf *TransactionFilterBuilderMock) AddImportId(value string) error {
+	tf.Filters[importIdFilter] = value
+	return nil
+}
+
 func (tf *TransactionFilterBuilderMock) GetFilters() []repositories.TransactionFilter {
 	return nil
 }
@@ -186,3 +200,23 @@ type TransactionFilterBuilderWithErrorMock struct {
 func (tf *TransactionFilterBuilderWithErrorMock) AddTransactionId(string) error {
 	return errors.New("some error")
 }
+
+func Test_negotiateContentType(t *testing.T) {
+	offered := []string{gin.MIMEJSON, "text/csv", ndjsonContentType}
+	cases := map[string]string{
+		"":                                       gin.MIMEJSON,
+		"*/*":                                    gin.MIMEJSON,
+		"text/*":                                 "text/csv",
+		"text/csv;q=0.5, application/*":          gin.MIMEJSON,
+		"application/x-ndjson, application/json": ndjsonContentType,
+		"application/json;q=0, */*;q=0.1":        "text/csv",
+		"application/jsonx, text/csv;charset=utf-8": "text/csv",
+		"image/png": "",
+	}
+	for accept, expected := range cases {
+		actual := negotiateContentType(accept, offered)
+		if actual != expected {
+			t.Errorf("%q: expected %q, actual %q", accept, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	WatchProcessedDirectory = "processed"
+	WatchFailedDirectory    = "failed"
+
+	// watchReportSuffix is appended to the name of the moved file to get the name of its report.
+	watchReportSuffix = ".result.json"
+)
+
+// watchedFile is the state of a file seen in the watched directory.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// watchReport is written next to the processed or failed file.
+// FileName is the path of the file after it is moved.
+type watchReport struct {
+	FileName   string    `json:"file_name"`
+	State      string    `json:"state"`
+	Message    string    `json:"message,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	// RejectedRowsPath is the file with rejected rows in the partial mode.
+	RejectedRowsPath string `json:"rejected_rows_path,omitempty"`
+
+	ImportResult
+}
+
+// WatchDirectory imports files dropped into the directory until ctx is done. The directory
+// is scanned at the interval, and a file is imported once its size and modification time
+// stay the same for a whole interval, so that files still being written are not read. Hidden
+// files and files with unknown extensions are ignored. Each imported file is moved to the
+// processed or failed subdirectory together with a report of the import.
+func (a *Application) WatchDirectory(
+	ctx context.Context,
+	dir, uploader string,
+	interval time.Duration,
+	options ImportOptions,
+) error {
+	for _, subdirectory := range []string{WatchProcessedDirectory, WatchFailedDirectory} {
+		err := os.MkdirAll(filepath.Join(dir, subdirectory), 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := map[string]watchedFile{}
+	for {
+		files, err := scanWatchedDirectory(dir, seen)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			err = a.processWatchedFile(ctx, dir, file, uploader, options)
+			if ctx.Err() != nil {
+				// the file is left in place to be imported again on the next start
+				return ctx.Err()
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanWatchedDirectory returns names of the files which did not change since the previous
+// scan. The states of the other files are saved to seen to be compared on the next scan.
+func scanWatchedDirectory(dir string, seen map[string]watchedFile) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	present := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || !isImportableFileName(name) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		present[name] = true
+		current := watchedFile{size: info.Size(), modTime: info.ModTime()}
+		previous, ok := seen[name]
+		if ok && previous == current {
+			files = append(files, name)
+			delete(seen, name)
+			continue
+		}
+
+		seen[name] = current
+	}
+
+	// forget the files which were removed or renamed
+	for name := range seen {
+		if !present[name] {
+			delete(seen, name)
+		}
+	}
+
+	return files, nil
+}
+
+// processWatchedFile imports the file and moves it with the report to the processed or failed
+// subdirectory, the import record is updated to refer to the moved file. Errors of the import
+// are written to the report, the returned error means that the file could not be moved.
+func (a *Application) processWatchedFile(ctx context.Context, dir, name, uploader string, options ImportOptions) error {
+	path := filepath.Join(dir, name)
+	report := watchReport{StartedAt: time.Now()}
+	result, err := a.ImportFile(ctx, path, uploader, options)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	report.FinishedAt = time.Now()
+	report.ImportResult = result
+	report.RejectedRowsPath = result.RejectedRowsPath
+	subdirectory := WatchFailedDirectory
+	switch {
+	case err != nil:
+		report.State = "failed"
+		report.Message = err.Error()
+	case len(result.Errors) > 0 && (!options.Partial || result.RejectedCount == 0):
+		report.State = "failed"
+		report.Message = errInvalidFileData.Error()
+	default:
+		report.State = "completed"
+		subdirectory = WatchProcessedDirectory
+	}
+
+	destination := getUnusedFilePath(filepath.Join(dir, subdirectory), name)
+	err = os.Rename(path, destination)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s: %s, moved to %s\n", name, report.State, destination)
+	report.FileName = destination
+	if result.ImportId != 0 {
+		err = a.updateImportFileName(result.ImportId, destination)
+		if err != nil {
+			// the file is imported and moved already, so the watcher is not stopped
+			log.Printf("unable to update the file name of import %d: %v\n", result.ImportId, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(destination+watchReportSuffix, data, 0644)
+}
+
+// updateImportFileName sets the file name of the import record to the new path of the file.
+func (a *Application) updateImportFileName(id uint64, path string) error {
+	repository := a.TransactionRepository.Imports()
+	record, err := repository.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	record.FileName = path
+	return repository.Save(record)
+}
+
+// getUnusedFilePath returns the path of the file in the directory. If the file
+// already exists, the name is prefixed with the current time to keep both files.
+func getUnusedFilePath(dir, name string) string {
+	path := filepath.Join(dir, name)
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return path
+	}
+
+	return filepath.Join(dir, time.Now().Format("20060102150405.000000000")+"-"+name)
+}
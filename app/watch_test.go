@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"TraineeGolangTestTask/models"
+)
+
+func Test_scanWatchedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.csv", ".b.csv", "c.csv.part"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]watchedFile{}
+	files, err := scanWatchedDirectory(dir, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected no files on the first scan, actual %v", files)
+	}
+
+	// the file is still being written
+	err = os.WriteFile(filepath.Join(dir, "a.csv"), []byte("more data"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err = scanWatchedDirectory(dir, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected no files after the change, actual %v", files)
+	}
+
+	files, err = scanWatchedDirectory(dir, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(files, []string{"a.csv"}) {
+		t.Errorf("expected %v, actual %v", []string{"a.csv"}, files)
+	}
+}
+
+func TestApplication_WatchDirectory(t *testing.T) {
+	repo := newTransactionRepositoryMock([]models.Transaction{})
+	app := Application{TransactionRepository: repo, DataDirectory: t.TempDir()}
+
+	t.Run("Processed", func(t *testing.T) { SubTestApplication_WatchDirectory_Processed(t, &app, repo) })
+	t.Run("Failed", func(t *testing.T) { SubTestApplication_WatchDirectory_Failed(t, &app, repo) })
+}
+
+func SubTestApplication_WatchDirectory_Processed(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.models = []models.Transaction{}
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(strings.Join(testData, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := runWatchDirectory(t, app, dir, filepath.Join(WatchProcessedDirectory, "transactions.csv"))
+	if report.State != "completed" || report.InsertedCount != 3 {
+		t.Errorf("expected 3 transactions to be inserted, actual %+v", report)
+	}
+
+	if len(repo.models) != 3 {
+		t.Errorf("expected transactions count is %d, actual is %d", 3, len(repo.models))
+	}
+
+	path := filepath.Join(dir, WatchProcessedDirectory, "transactions.csv")
+	if report.FileName != path {
+		t.Errorf("expected file name %s, actual %s", path, report.FileName)
+	}
+
+	record := repo.imports[len(repo.imports)-1]
+	if record.Id != report.ImportId || record.FileName != path {
+		t.Errorf("expected import %d of the file %s, actual %+v", report.ImportId, path, record)
+	}
+}
+
+func SubTestApplication_WatchDirectory_Failed(t *testing.T, app *Application, repo *transactionRepositoryMock) {
+	repo.models = []models.Transaction{}
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(testData[0]+"\n1,2,3"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := runWatchDirectory(t, app, dir, filepath.Join(WatchFailedDirectory, "transactions.csv"))
+	if report.State != "failed" || report.Message != errInvalidFileData.Error() || len(report.Errors) != 1 {
+		t.Errorf("expected the file to fail with 1 row error, actual %+v", report)
+	}
+
+	if len(repo.models) != 0 {
+		t.Errorf("expected no transactions to be saved, actual %+v", repo.models)
+	}
+
+	path := filepath.Join(dir, WatchFailedDirectory, "transactions.csv")
+	if report.FileName != path {
+		t.Errorf("expected file name %s, actual %s", path, report.FileName)
+	}
+}
+
+// runWatchDirectory watches the directory until the file is moved to
+// the expected path, then the report of the file is returned.
+func runWatchDirectory(t *testing.T, app *Application, dir, expectedPath string) watchReport {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- app.WatchDirectory(ctx, dir, "partner", 10*time.Millisecond, ImportOptions{})
+	}()
+
+	reportPath := filepath.Join(dir, expectedPath) + watchReportSuffix
+	for {
+		_, err := os.Stat(reportPath)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	err := <-done
+	if err != context.Canceled {
+		t.Errorf("expected %v, actual %v", context.Canceled, err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, expectedPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := watchReport{}
+	err = json.Unmarshal(data, &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"TraineeGolangTestTask/app"
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	exportOutput string
+	exportFields string
+
+	// exportFilterFlags are named as the query parameters of the
+	// transaction endpoints, with dashes instead of underscores
+	exportFilterFlags = newExportFilterFlags()
+
+	exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export transactions to a Parquet file",
+		Long: `Export the filtered transactions to a Parquet file, which is the same as the file
+returned by the /api/transactions/parquet endpoint. The amounts are written as decimals
+and the dates as timestamps, so the file can be loaded by DuckDB, Spark and other tools
+without parsing.`,
+		Args: cobra.NoArgs,
+		RunE: runExportCommand,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(
+		&exportOutput, "output", "o", "", "path of the Parquet file, - writes it to the standard output",
+	)
+	exportCmd.Flags().StringVar(
+		&exportFields, "fields", "", "comma-separated list of the exported columns (default all columns)",
+	)
+	exportCmd.Flags().AddFlagSet(exportFilterFlags)
+	_ = exportCmd.MarkFlagRequired("output")
+}
+
+func newExportFilterFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("filters", pflag.ContinueOnError)
+	flags.String("transaction-id", "", "filter by the transaction id")
+	flags.StringSlice("terminal-id", nil, "filter by the terminal ids")
+	flags.String("status", "", "filter by the status: accepted or declined")
+	flags.String("payment-type", "", "filter by the payment type: cash or card")
+	flags.String("date-post-from", "", `filter by the post date from, such as "2022-08-12 00:00:00"`)
+	flags.String("date-post-to", "", "filter by the post date to, required with --date-post-from")
+	flags.String("payment-narrative", "", "filter by a part of the payment narrative")
+	flags.String("import-id", "", "filter by the import which saved the transactions")
+	return flags
+}
+
+func runExportCommand(*cobra.Command, []string) error {
+	projection, err := models.ParseTransactionProjection(exportFields)
+	if err != nil {
+		return fmt.Errorf("invalid --fields flag: %v", err)
+	}
+
+	db, err := app.ConnectToPostgreSQLWithEnv()
+	if err != nil {
+		return err
+	}
+
+	application := app.Application{TransactionRepository: repositories.NewTransactionRepository(db)}
+	filters, err := application.ParseTransactionFilters(getExportParameters(exportFilterFlags))
+	if err != nil {
+		return err
+	}
+
+	if exportOutput == "-" {
+		return application.ExportTransactionsAsParquet(os.Stdout, filters, projection)
+	}
+
+	file, err := os.Create(exportOutput)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	err = application.ExportTransactionsAsParquet(writer, filters, projection)
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		// the incomplete file can not be read
+		_ = os.Remove(exportOutput)
+		return err
+	}
+
+	log.Printf("Exported transactions to %s\n", exportOutput)
+	return nil
+}
+
+// getExportParameters returns values of the filter flags which are set by their query parameter names.
+func getExportParameters(flags *pflag.FlagSet) url.Values {
+	parameters := url.Values{}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if !flag.Changed {
+			return
+		}
+
+		name := strings.ReplaceAll(flag.Name, "-", "_")
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			parameters[name] = slice.GetSlice()
+		} else {
+			parameters.Set(name, flag.Value.String())
+		}
+	})
+
+	return parameters
+}
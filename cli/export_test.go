@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func Test_getExportParameters(t *testing.T) {
+	flags := newExportFilterFlags()
+	err := flags.Parse([]string{
+		"--status", "accepted",
+		"--terminal-id", "3506,3507",
+		"--date-post-from", "2022-08-12 00:00:00",
+		"--date-post-to", "2022-08-13 00:00:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := url.Values{
+		"status":         {"accepted"},
+		"terminal_id":    {"3506", "3507"},
+		"date_post_from": {"2022-08-12 00:00:00"},
+		"date_post_to":   {"2022-08-13 00:00:00"},
+	}
+	actual := getExportParameters(flags)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"TraineeGolangTestTask/app"
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	importDryRun     bool
+	importPartial    bool
+	importForce      bool
+	importOnConflict string
+	importBatchSize  int
+	importEncoding   string
+	importDialect    string
+	importUploader   string
+
+	importCmd = &cobra.Command{
+		Use:   "import PATH...",
+		Short: "Import transactions from local files",
+		Long: `Import transactions from CSV, JSON and NDJSON files, which may be compressed
+with gzip or stored in zip archives. Directories are searched for such files
+recursively. Each file is imported separately, the same way as an uploaded file.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runImportCommand,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	addImportFlags(importCmd.Flags())
+	importCmd.Flags().BoolVar(
+		&importDryRun, "dry-run", false, "validate the files and check the ids for conflicts without saving",
+	)
+}
+
+// addImportFlags adds the flags used by newImportOptions.
+func addImportFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&importPartial, "partial", false, "save the valid rows of the files with invalid rows")
+	flags.BoolVar(&importForce, "force", false, "import the files which were already imported")
+	flags.StringVar(
+		&importOnConflict, "on-conflict", string(repositories.ConflictError),
+		"what to do with the existing transactions: error, skip or update",
+	)
+	flags.IntVar(
+		&importBatchSize, "batch-size", 0,
+		"number of transactions saved at once, the batches of large files grow automatically if it is 0",
+	)
+	flags.StringVar(&importEncoding, "encoding", "", "encoding of the files, such as windows-1251 (default utf-8)")
+	flags.StringVar(&importDialect, "dialect", "default", "CSV dialect of the files: default or excel-ua")
+	flags.StringVar(&importUploader, "uploader", os.Getenv("USER"), "name of the user saved with the imports")
+}
+
+func runImportCommand(_ *cobra.Command, paths []string) error {
+	options, err := newImportOptions()
+	if err != nil {
+		return err
+	}
+
+	files, err := app.ListImportFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("no files to import")
+	}
+
+	db, err := app.ConnectToPostgreSQLWithEnv()
+	if err != nil {
+		return err
+	}
+
+	application := app.Application{
+		MaxUploadErrors:       getIntFromEnvOrDefault(app.EnvAppMaxUploadErrors, app.DefaultAppMaxUploadErrors),
+		DataDirectory:         os.Getenv(app.EnvAppDataDirectory),
+		TransactionRepository: repositories.NewTransactionRepository(db),
+	}
+
+	// the running import is rolled back on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	failed := 0
+	for _, file := range files {
+		err = importFile(ctx, &application, file, options)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			log.Printf("%s: %v\n", file, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files were not imported", failed, len(files))
+	}
+
+	return nil
+}
+
+// newImportOptions validates the flags of the command.
+func newImportOptions() (app.ImportOptions, error) {
+	onConflict, err := repositories.ParseConflictStrategy(importOnConflict)
+	if err != nil {
+		return app.ImportOptions{}, fmt.Errorf("invalid --on-conflict flag: %v", err)
+	}
+
+	if importBatchSize < 0 {
+		return app.ImportOptions{}, errors.New("--batch-size should not be negative")
+	}
+
+	textEncoding, err := app.GetTextEncoding(importEncoding)
+	if err != nil {
+		return app.ImportOptions{}, err
+	}
+
+	dialect, ok := models.CsvDialectPresets[importDialect]
+	if !ok {
+		return app.ImportOptions{}, fmt.Errorf("unknown CSV dialect %q", importDialect)
+	}
+
+	return app.ImportOptions{
+		Partial:    importPartial,
+		DryRun:     importDryRun,
+		OnConflict: onConflict,
+		Force:      importForce,
+		Encoding:   textEncoding,
+		Dialect:    dialect,
+		BatchSize:  importBatchSize,
+	}, nil
+}
+
+func importFile(ctx context.Context, application *app.Application, file string, options app.ImportOptions) error {
+	options.Progress = func(rowsProcessed int) {
+		log.Printf("%s: %d rows processed\n", file, rowsProcessed)
+	}
+
+	result, err := application.ImportFile(ctx, file, importUploader, options)
+	if err != nil {
+		return err
+	}
+
+	for _, rowError := range result.Errors {
+		log.Printf("%s: %v\n", file, models.RowErrors{rowError})
+	}
+
+	if result.ErrorsTruncated {
+		log.Printf("%s: the errors are truncated\n", file)
+	}
+
+	if options.DryRun {
+		log.Printf(
+			"%s: %d valid rows, %d invalid rows, %d conflicting ids\n",
+			file, result.RowCount, result.RejectedCount, len(result.ConflictingIds),
+		)
+		return nil
+	}
+
+	if len(result.Errors) > 0 && (!options.Partial || result.RejectedCount == 0) {
+		// in the partial mode, only errors in the header reject the whole file
+		return errors.New("invalid file data, nothing was saved")
+	}
+
+	log.Printf(
+		"%s: %d rows saved as import %d: %d inserted, %d skipped, %d updated, %d rejected\n",
+		file, result.RowCount, result.ImportId, result.InsertedCount, result.SkippedCount,
+		result.UpdatedCount, result.RejectedCount,
+	)
+	if result.RejectedRowsPath != "" {
+		log.Printf("%s: rejected rows are written to %s\n", file, result.RejectedRowsPath)
+	}
+
+	return nil
+}
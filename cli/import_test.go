@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"testing"
+
+	"TraineeGolangTestTask/models"
+	"TraineeGolangTestTask/repositories"
+)
+
+func Test_newImportOptions(t *testing.T) {
+	importOnConflict, importBatchSize, importEncoding, importDialect = "skip", 100, "windows-1251", "excel-ua"
+	defer func() {
+		importOnConflict, importBatchSize, importEncoding, importDialect = "error", 0, "", "default"
+	}()
+
+	options, err := newImportOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.OnConflict != repositories.ConflictSkip {
+		t.Errorf("expected %s, actual %s", repositories.ConflictSkip, options.OnConflict)
+	}
+
+	if options.BatchSize != 100 {
+		t.Errorf("expected %d, actual %d", 100, options.BatchSize)
+	}
+
+	if options.Encoding == nil {
+		t.Errorf("expected the encoding to be set")
+	}
+
+	if options.Dialect != models.CsvDialectPresets["excel-ua"] {
+		t.Errorf("expected %+v, actual %+v", models.CsvDialectPresets["excel-ua"], options.Dialect)
+	}
+}
+
+func Test_newImportOptions_InvalidFlags(t *testing.T) {
+	invalid := map[string]func(){
+		"OnConflict": func() { importOnConflict = "replace" },
+		"BatchSize":  func() { importBatchSize = -1 },
+		"Encoding":   func() { importEncoding = "unknown" },
+		"Dialect":    func() { importDialect = "unknown" },
+	}
+	for name, setFlag := range invalid {
+		importOnConflict, importBatchSize, importEncoding, importDialect = "error", 0, "", "default"
+		setFlag()
+		_, err := newImportOptions()
+		if err == nil {
+			t.Errorf("expected an error for the invalid %s flag", name)
+		}
+	}
+
+	importOnConflict, importBatchSize, importEncoding, importDialect = "error", 0, "", "default"
+}
@@ -4,8 +4,10 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"TraineeGolangTestTask/app"
+	"TraineeGolangTestTask/models"
 	"TraineeGolangTestTask/repositories"
 	"github.com/spf13/cobra"
 )
@@ -35,9 +37,21 @@ func runRootCommand(*cobra.Command, []string) error {
 		return err
 	}
 
+	bankDirectory, err := loadBankDirectoryFromEnv()
+	if err != nil {
+		return err
+	}
+
+	rejectedRowsTtl := getIntFromEnvOrDefault(app.EnvAppRejectedRowsTtl, app.DefaultAppRejectedRowsTtl)
 	application := app.Application{
 		PageSize:              getPageSizeFromEnvOrDefault(app.DefaultAppPageSize),
+		MaxUploadErrors:       getIntFromEnvOrDefault(app.EnvAppMaxUploadErrors, app.DefaultAppMaxUploadErrors),
+		DataDirectory:         os.Getenv(app.EnvAppDataDirectory),
+		UploadWorkers:         getIntFromEnvOrDefault(app.EnvAppUploadWorkers, app.DefaultAppUploadWorkers),
+		RejectedRowsTtl:       time.Duration(rejectedRowsTtl) * time.Hour,
+		BankDirectory:         bankDirectory,
 		TransactionRepository: repositories.NewTransactionRepository(db),
+		UploadJobRepository:   repositories.NewUploadJobRepository(db),
 	}
 
 	log.Printf("Serving at %s\n", addressArg)
@@ -45,13 +59,28 @@ func runRootCommand(*cobra.Command, []string) error {
 }
 
 func getPageSizeFromEnvOrDefault(defaultValue int) int {
-	pageSizeString := os.Getenv(app.EnvAppPageSize)
-	if pageSizeString != "" {
-		parsedPageSize, err := strconv.Atoi(pageSizeString)
+	return getIntFromEnvOrDefault(app.EnvAppPageSize, defaultValue)
+}
+
+func getIntFromEnvOrDefault(key string, defaultValue int) int {
+	valueString := os.Getenv(key)
+	if valueString != "" {
+		parsedValue, err := strconv.Atoi(valueString)
 		if err == nil {
-			return parsedPageSize
+			return parsedValue
 		}
 	}
 
 	return defaultValue
 }
+
+// loadBankDirectoryFromEnv loads the bank directory from the file set in the environment,
+// the bundled directory is used by default. Names of the banks are disabled by "none".
+func loadBankDirectoryFromEnv() (models.BankDirectory, error) {
+	path := os.Getenv(app.EnvAppBankDirectory)
+	if path == "none" {
+		return nil, nil
+	}
+
+	return models.LoadBankDirectory(path)
+}
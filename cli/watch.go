@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"TraineeGolangTestTask/app"
+	"TraineeGolangTestTask/repositories"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchDirectory string
+	watchInterval  time.Duration
+
+	watchCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Import transactions from files dropped into a directory",
+		Long: `Watch the directory for new CSV, JSON and NDJSON files and import each file once
+it is fully written. Imported files are moved to the processed subdirectory, and files
+which could not be imported are moved to the failed subdirectory. A report of the
+import is written next to each moved file.`,
+		Args: cobra.NoArgs,
+		RunE: runWatchCommand,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	addImportFlags(watchCmd.Flags())
+	watchCmd.Flags().StringVar(&watchDirectory, "dir", "", "directory to watch")
+	watchCmd.Flags().DurationVar(
+		&watchInterval, "interval", 5*time.Second,
+		"interval between the scans of the directory, files are imported when they do not change for it",
+	)
+	_ = watchCmd.MarkFlagRequired("dir")
+}
+
+func runWatchCommand(*cobra.Command, []string) error {
+	options, err := newImportOptions()
+	if err != nil {
+		return err
+	}
+
+	if watchInterval <= 0 {
+		return errors.New("--interval should be positive")
+	}
+
+	info, err := os.Stat(watchDirectory)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return errors.New("--dir should be a directory")
+	}
+
+	db, err := app.ConnectToPostgreSQLWithEnv()
+	if err != nil {
+		return err
+	}
+
+	application := app.Application{
+		MaxUploadErrors:       getIntFromEnvOrDefault(app.EnvAppMaxUploadErrors, app.DefaultAppMaxUploadErrors),
+		DataDirectory:         os.Getenv(app.EnvAppDataDirectory),
+		TransactionRepository: repositories.NewTransactionRepository(db),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Watching %s\n", watchDirectory)
+	err = application.WatchDirectory(ctx, watchDirectory, importUploader, watchInterval, options)
+	if errors.Is(err, context.Canceled) {
+		// the file being imported is rolled back and left to be imported on the next start
+		log.Println("Stopped watching.")
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// bundledBankDirectory is a directory of the major Ukrainian banks, it is used
+// unless another directory is configured.
+//
+//go:embed banks.csv
+var bundledBankDirectory []byte
+
+// BankDirectory maps MFO codes of banks to their names. A nil directory is
+// valid and contains no banks.
+type BankDirectory map[uint32]string
+
+// LoadBankDirectory reads the directory from a CSV file with the "Mfo" and "Name"
+// columns. The bundled directory is returned if path is empty.
+func LoadBankDirectory(path string) (BankDirectory, error) {
+	if path == "" {
+		return ReadBankDirectory(bytes.NewReader(bundledBankDirectory))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	return ReadBankDirectory(file)
+}
+
+// ReadBankDirectory reads the CSV data of the directory, the columns
+// of the data are matched the same way as the transaction columns.
+func ReadBankDirectory(r io.Reader) (BankDirectory, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid bank directory: %w", err)
+	}
+
+	mfoIndex, nameIndex := -1, -1
+	for i, column := range header {
+		switch NormalizeCsvColumnName(column) {
+		case "mfo":
+			mfoIndex = i
+		case "name":
+			nameIndex = i
+		}
+	}
+
+	if mfoIndex == -1 || nameIndex == -1 {
+		return nil, errors.New("invalid bank directory: the Mfo and Name columns are required")
+	}
+
+	directory := BankDirectory{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return directory, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid bank directory: %w", err)
+		}
+
+		mfo, err := strconv.ParseUint(record[mfoIndex], 10, 32)
+		if err != nil {
+			line, _ := reader.FieldPos(mfoIndex)
+			return nil, fmt.Errorf("invalid bank directory: invalid MFO in line %d: %w", line, err)
+		}
+
+		directory[uint32(mfo)] = record[nameIndex]
+	}
+}
+
+// Name returns the name of the bank or an empty string if the bank is unknown.
+func (d BankDirectory) Name(mfo uint32) string {
+	return d[mfo]
+}
+
+// SetPayeeBankNames sets PayeeBankName of the transactions whose banks are known.
+func (d BankDirectory) SetPayeeBankNames(transactions []Transaction) {
+	for i := range transactions {
+		transactions[i].PayeeBankName = d.Name(transactions[i].PayeeBankMfo)
+	}
+}
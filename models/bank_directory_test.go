@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadBankDirectory_Bundled(t *testing.T) {
+	directory, err := LoadBankDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "АТ КБ \"ПРИВАТБАНК\""
+	if directory.Name(305299) != expected {
+		t.Errorf("%v != %v", expected, directory.Name(305299))
+	}
+}
+
+func TestReadBankDirectory_SnakeCaseColumns(t *testing.T) {
+	directory, err := ReadBankDirectory(strings.NewReader("name,bank_code,mfo\npumb,x,254751\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := []Transaction{{PayeeBankMfo: 254751}, {PayeeBankMfo: 255752}}
+	directory.SetPayeeBankNames(transactions)
+	if transactions[0].PayeeBankName != "pumb" || transactions[1].PayeeBankName != "" {
+		t.Errorf("expected names \"pumb\" and \"\", received %+v", transactions)
+	}
+}
+
+func TestReadBankDirectory_InvalidData(t *testing.T) {
+	for _, data := range []string{"", "Mfo,Bank\n305299,privat\n", "Mfo,Name\nx,privat\n"} {
+		_, err := ReadBankDirectory(strings.NewReader(data))
+		if err == nil {
+			t.Errorf("error is nil for %q", data)
+		}
+	}
+}
+
+func TestBankDirectory_SetPayeeBankNames_Nil(t *testing.T) {
+	var directory BankDirectory
+	transactions := []Transaction{{PayeeBankMfo: 305299}}
+	directory.SetPayeeBankNames(transactions)
+	if transactions[0].PayeeBankName != "" {
+		t.Errorf("expected no name, received %q", transactions[0].PayeeBankName)
+	}
+}
@@ -0,0 +1,342 @@
+package models
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// RowError describes a problem found in a row of an uploaded file.
+type RowError struct {
+	// File is the name of the file from an uploaded archive.
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// RowErrors is returned by TransactionCsvReader when the data of a row is invalid.
+// Errors of other types are caused by the underlying reader.
+type RowErrors []RowError
+
+func (e RowErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		if err.Column != "" {
+			messages[i] = fmt.Sprintf("line %d, column %s: %s", err.Line, err.Column, err.Reason)
+		} else {
+			messages[i] = fmt.Sprintf("line %d: %s", err.Line, err.Reason)
+		}
+
+		if err.File != "" {
+			messages[i] = fmt.Sprintf("file %s, %s", err.File, messages[i])
+		}
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// errInvalidUtf8 is reported for values which are not valid UTF-8, usually
+// because the file has another encoding which was not specified on upload.
+var errInvalidUtf8 = errors.New("invalid UTF-8 data, the encoding of the file may be wrong")
+
+// CsvHeaderMapping maps columns of a CSV file to the transaction fields.
+type CsvHeaderMapping struct {
+	header []string
+
+	// indexes[i] is the index of the record field which holds
+	// the value of the i-th column in transactionColumns
+	indexes []int
+}
+
+var defaultCsvHeaderMapping = newDefaultCsvHeaderMapping()
+
+func newDefaultCsvHeaderMapping() *CsvHeaderMapping {
+	mapping := &CsvHeaderMapping{
+		header:  TransactionCsvHeader,
+		indexes: make([]int, len(transactionColumns)),
+	}
+	for i := range transactionColumns {
+		mapping.indexes[i] = i
+	}
+
+	return mapping
+}
+
+// NewCsvHeaderMapping matches header names to the transaction columns regardless of
+// their order. Names are compared case-insensitively with underscores, dashes and
+// spaces ignored, so both "PayeeBankMfo" and "payee_bank_mfo" are recognized.
+// Columns which are not known are ignored, unless rejectUnknown is true.
+func NewCsvHeaderMapping(header []string, rejectUnknown bool) (*CsvHeaderMapping, error) {
+	columnIndexes := map[string]int{}
+	for i, column := range transactionColumns {
+		columnIndexes[NormalizeCsvColumnName(column.name)] = i
+	}
+
+	mapping := &CsvHeaderMapping{
+		header:  append([]string{}, header...),
+		indexes: make([]int, len(transactionColumns)),
+	}
+	for i := range mapping.indexes {
+		mapping.indexes[i] = -1
+	}
+
+	var unknown []string
+	for i, name := range header {
+		columnIndex, ok := columnIndexes[NormalizeCsvColumnName(name)]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if mapping.indexes[columnIndex] != -1 {
+			return nil, fmt.Errorf("duplicate column: %s", name)
+		}
+
+		mapping.indexes[columnIndex] = i
+	}
+
+	var missing []string
+	for i, index := range mapping.indexes {
+		if index == -1 {
+			missing = append(missing, transactionColumns[i].name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required columns: %s", strings.Join(missing, ", "))
+	}
+
+	if rejectUnknown && len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown columns: %s", strings.Join(unknown, ", "))
+	}
+
+	return mapping, nil
+}
+
+func (m *CsvHeaderMapping) newTransaction(record []string, dialect *CsvDialect) (Transaction, error) {
+	var fieldErrors FieldErrors
+	transaction := Transaction{}
+	for i, column := range transactionColumns {
+		index := m.indexes[i]
+		if !utf8.ValidString(record[index]) {
+			// the value is not parsed, it would be saved with broken characters
+			fieldErrors = append(fieldErrors, &FieldError{Index: index, Value: record[index], Err: errInvalidUtf8})
+			continue
+		}
+
+		err := column.parse(&transaction, record[index], dialect)
+		if err != nil {
+			fieldErrors = append(fieldErrors, &FieldError{Index: index, Value: record[index], Err: err})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return Transaction{}, fieldErrors
+	}
+
+	err := transaction.Validate()
+	if errors.As(err, &fieldErrors) {
+		// the errors refer to the columns, which are mapped to the record fields
+		for _, fieldErr := range fieldErrors {
+			fieldErr.Index = m.indexes[fieldErr.Index]
+			fieldErr.Value = record[fieldErr.Index]
+		}
+
+		return Transaction{}, fieldErrors
+	}
+
+	return transaction, nil
+}
+
+// NormalizeCsvColumnName returns the form of the column name used for matching
+// columns, so that both `PayeeBankMfo` and `payee_bank_mfo` refer to the same column.
+func NormalizeCsvColumnName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.TrimSpace(name)))
+}
+
+// TransactionCsvReader reads transactions from RFC 4180 CSV data.
+// Quoted fields may contain delimiters, escaped quotes and line breaks,
+// and both LF and CRLF line endings are accepted. As in encoding/csv, CRLF
+// line breaks inside quoted fields are read as LF, so a field written with
+// CRLF does not read back byte for byte.
+// Until ReadHeader is called, fields are expected in the order of TransactionCsvHeader.
+type TransactionCsvReader struct {
+	reader  *csv.Reader
+	dialect CsvDialect
+	mapping *CsvHeaderMapping
+	record  []string
+}
+
+// NewTransactionCsvReader creates a reader of the data in DefaultCsvDialect.
+func NewTransactionCsvReader(r io.Reader) *TransactionCsvReader {
+	return NewTransactionCsvDialectReader(r, DefaultCsvDialect)
+}
+
+// NewTransactionCsvDialectReader creates a reader of the data in the dialect,
+// which should be valid.
+func NewTransactionCsvDialectReader(r io.Reader, dialect CsvDialect) *TransactionCsvReader {
+	reader := csv.NewReader(r)
+	reader.Comma = dialect.Delimiter
+	reader.ReuseRecord = true
+	return &TransactionCsvReader{reader: reader, dialect: dialect, mapping: defaultCsvHeaderMapping}
+}
+
+// ReadHeader reads the first record and maps the columns of the following
+// records by names from it (see NewCsvHeaderMapping). The number of fields
+// in the header is used to check the number of fields in each record.
+// Nothing is read if the dialect has no header.
+func (r *TransactionCsvReader) ReadHeader(rejectUnknownColumns bool) error {
+	if !r.dialect.Header {
+		return nil
+	}
+
+	header, err := r.reader.Read()
+	if err != nil {
+		return r.wrapReadError(err)
+	}
+
+	mapping, err := NewCsvHeaderMapping(header, rejectUnknownColumns)
+	if err != nil {
+		return RowErrors{{Line: 1, Reason: err.Error()}}
+	}
+
+	r.mapping = mapping
+	return nil
+}
+
+// Read returns the next transaction or io.EOF if there are no more records.
+// If the row is invalid, RowErrors with all problems found in it is returned
+// and the reading may be continued with the next row.
+func (r *TransactionCsvReader) Read() (Transaction, error) {
+	record, err := r.reader.Read()
+	r.record = record
+	if err != nil {
+		return Transaction{}, r.wrapReadError(err)
+	}
+
+	if r.mapping == defaultCsvHeaderMapping && len(record) != numberOfTransactionFields {
+		line, _ := r.reader.FieldPos(0)
+		return Transaction{}, RowErrors{
+			{
+				Line: line,
+				Reason: fmt.Sprintf(
+					"invalid number of transaction fields: %d required, %d got",
+					numberOfTransactionFields,
+					len(record),
+				),
+			},
+		}
+	}
+
+	transaction, err := r.mapping.newTransaction(record, &r.dialect)
+	if err != nil {
+		var fieldErrors FieldErrors
+		if !errors.As(err, &fieldErrors) {
+			return Transaction{}, err
+		}
+
+		rowErrors := make(RowErrors, len(fieldErrors))
+		for i, fieldErr := range fieldErrors {
+			line, _ := r.reader.FieldPos(fieldErr.Index)
+			rowErrors[i] = RowError{
+				Line:   line,
+				Column: r.mapping.header[fieldErr.Index],
+				Value:  fieldErr.Value,
+				Reason: fieldErr.Err.Error(),
+			}
+		}
+
+		return Transaction{}, rowErrors
+	}
+
+	return transaction, nil
+}
+
+// Header returns column names of the file. If ReadHeader was not called,
+// TransactionCsvHeader is returned.
+func (r *TransactionCsvReader) Header() []string {
+	return r.mapping.header
+}
+
+// Record returns raw fields of the row returned by the last call to Read.
+// The slice is valid until the next call to Read.
+func (r *TransactionCsvReader) Record() []string {
+	return r.record
+}
+
+func (r *TransactionCsvReader) wrapReadError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		reason := parseErr.Err.Error()
+		if parseErr.Err != csv.ErrFieldCount {
+			reason = fmt.Sprintf("%s (column %d)", reason, parseErr.Column)
+		}
+
+		return RowErrors{{Line: parseErr.Line, Reason: reason}}
+	}
+
+	return err
+}
+
+// TransactionCsvWriter writes transactions as RFC 4180 CSV data. Fields that
+// contain delimiters, quotes or line breaks are quoted and escaped, line breaks
+// inside the fields are written as they are.
+type TransactionCsvWriter struct {
+	output     io.Writer
+	writer     *csv.Writer
+	dialect    CsvDialect
+	projection TransactionProjection
+}
+
+// NewTransactionCsvWriter creates a writer of the data in DefaultCsvDialect.
+func NewTransactionCsvWriter(w io.Writer) *TransactionCsvWriter {
+	return NewTransactionCsvDialectWriter(w, DefaultCsvDialect)
+}
+
+// NewTransactionCsvDialectWriter creates a writer of the data in the dialect,
+// which should be valid.
+func NewTransactionCsvDialectWriter(w io.Writer, dialect CsvDialect) *TransactionCsvWriter {
+	writer := csv.NewWriter(w)
+	writer.Comma = dialect.Delimiter
+	return &TransactionCsvWriter{output: w, writer: writer, dialect: dialect}
+}
+
+// WriteHeader writes the byte order mark and the header row if they
+// are enabled by the dialect. It should be called before Write.
+func (w *TransactionCsvWriter) WriteHeader() error {
+	if w.dialect.Bom {
+		// nothing is buffered by the CSV writer yet, so the mark is written first
+		_, err := io.WriteString(w.output, Utf8Bom)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !w.dialect.Header {
+		return nil
+	}
+
+	return w.writer.Write(w.projection.Header())
+}
+
+// SetProjection selects the columns to be written in the order of the
+// projection. It should be called before WriteHeader.
+func (w *TransactionCsvWriter) SetProjection(projection TransactionProjection) {
+	w.projection = projection
+}
+
+func (w *TransactionCsvWriter) Write(transaction Transaction) error {
+	return w.writer.Write(w.projection.project(transaction.toCsvRecord(&w.dialect)))
+}
+
+// Flush writes any buffered data to the underlying io.Writer and
+// returns an error that occurred during a previous write or flush.
+func (w *TransactionCsvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
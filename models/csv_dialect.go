@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CsvDialect describes the format of CSV files, so that files produced by
+// spreadsheet applications with regional settings can be imported and exported.
+type CsvDialect struct {
+	Delimiter rune `json:"delimiter"`
+
+	// DecimalSeparator is either '.' or ','. The other character is not
+	// accepted in numbers, as it may be a thousands separator.
+	DecimalSeparator rune `json:"decimal_separator"`
+
+	// TimeLayout is a layout of the time package used for DateInput and DatePost.
+	TimeLayout string `json:"time_layout"`
+
+	// Header is false for files without the header row, their columns
+	// are expected in the order of TransactionCsvHeader.
+	Header bool `json:"header"`
+
+	// Bom enables writing of the UTF-8 byte order mark, which makes Excel
+	// recognize the encoding. The mark is always skipped on import.
+	Bom bool `json:"bom"`
+}
+
+// Utf8Bom is the UTF-8 byte order mark.
+const Utf8Bom = "\ufeff"
+
+// DefaultCsvDialect is the RFC 4180 format used when no dialect options are given.
+var DefaultCsvDialect = CsvDialect{
+	Delimiter:        ',',
+	DecimalSeparator: '.',
+	TimeLayout:       TimeLayout,
+	Header:           true,
+}
+
+// CsvDialectPresets contains named dialects, which may be used as a base for the options.
+var CsvDialectPresets = map[string]CsvDialect{
+	"default": DefaultCsvDialect,
+
+	// excel-ua is the format of Excel with the Ukrainian regional settings
+	"excel-ua": {
+		Delimiter:        ';',
+		DecimalSeparator: ',',
+		TimeLayout:       "02.01.2006 15:04:05",
+		Header:           true,
+		Bom:              true,
+	},
+}
+
+// Validate checks that the delimiter and the separator are distinct characters which
+// may be used in CSV data, and that the time layout keeps all parts of the time.
+func (d CsvDialect) Validate() error {
+	if d.Delimiter == '"' || d.Delimiter == '\r' || d.Delimiter == '\n' ||
+		d.Delimiter == utf8.RuneError || !utf8.ValidRune(d.Delimiter) {
+		return fmt.Errorf("invalid delimiter %q", d.Delimiter)
+	}
+
+	if d.DecimalSeparator != '.' && d.DecimalSeparator != ',' {
+		return errors.New("the decimal separator should be either \".\" or \",\"")
+	}
+
+	if d.DecimalSeparator == d.Delimiter {
+		return errors.New("the decimal separator should differ from the delimiter")
+	}
+
+	reference := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	parsed, err := time.Parse(d.TimeLayout, reference.Format(d.TimeLayout))
+	if err != nil || !parsed.Equal(reference) {
+		return fmt.Errorf("the time layout %q should contain a date and a time with seconds", d.TimeLayout)
+	}
+
+	return nil
+}
+
+func (d *CsvDialect) formatFloat32(v float32) string {
+	value := formatFloat32(v)
+	if d.DecimalSeparator != '.' {
+		value = strings.Replace(value, ".", string(d.DecimalSeparator), 1)
+	}
+
+	return value
+}
+
+func (d *CsvDialect) parseFloat32(s string) (float32, error) {
+	if d.DecimalSeparator == '.' {
+		return parseFloat32(s)
+	}
+
+	if strings.ContainsRune(s, '.') {
+		return 0, &strconv.NumError{Func: "ParseFloat", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	v, err := parseFloat32(strings.Replace(s, string(d.DecimalSeparator), ".", 1))
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		// the error refers to the original value
+		numErr.Num = s
+	}
+
+	return v, err
+}
+
+func (d *CsvDialect) formatTime(t time.Time) string {
+	return t.Format(d.TimeLayout)
+}
+
+func (d *CsvDialect) parseTime(s string) (time.Time, error) {
+	return time.Parse(d.TimeLayout, s)
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const excelUaTestRow = "1;20020;3506;1111;1,00;1,00;0,00;0,00;-0,01;12.08.2022 11:25:27;12.08.2022 14:25:27;accepted;cash;PS16698205;13980;Поповнення карток;14232155;pumb;254751;UA713451373919523;Перерахування коштів"
+
+func TestTransactionCsvReader_Read_ExcelUaDialect(t *testing.T) {
+	csvData := strings.Join(TransactionCsvHeader, ";") + "\r\n" + excelUaTestRow + "\r\n"
+	reader := NewTransactionCsvDialectReader(strings.NewReader(csvData), CsvDialectPresets["excel-ua"])
+	err := reader.ReadHeader(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewTransactionFromCSVRow(
+		strings.Split(
+			"1,20020,3506,1111,1.00,1.00,0.00,0.00,-0.01,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів",
+			",",
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(transaction, expected) {
+		t.Errorf("%+v != %+v", expected, transaction)
+	}
+
+	_, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("%v != %v", io.EOF, err)
+	}
+}
+
+func TestTransactionCsvReader_Read_DecimalPointInCommaDialect(t *testing.T) {
+	dialect := CsvDialectPresets["excel-ua"]
+	dialect.Header = false
+	reader := NewTransactionCsvDialectReader(
+		strings.NewReader(strings.Replace(excelUaTestRow, "-0,01", "-0.01", 1)),
+		dialect,
+	)
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	rowErrors, ok := err.(RowErrors)
+	if !ok || len(rowErrors) != 1 || rowErrors[0].Column != "CommissionProvider" || rowErrors[0].Value != "-0.01" {
+		t.Errorf("expected an error of CommissionProvider, received %v", err)
+	}
+}
+
+func TestTransactionCsvWriter_Write_ExcelUaDialect(t *testing.T) {
+	transaction, err := NewTransactionCsvDialectReader(strings.NewReader(excelUaTestRow), CsvDialectPresets["excel-ua"]).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := strings.Builder{}
+	writer := NewTransactionCsvDialectWriter(&builder, CsvDialectPresets["excel-ua"])
+	err = writer.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Write(transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Utf8Bom + strings.Join(TransactionCsvHeader, ";") + "\n" + excelUaTestRow + "\n"
+	if builder.String() != expected {
+		t.Errorf("\n%v\n!=\n%v", expected, builder.String())
+	}
+}
+
+func TestCsvDialect_Validate(t *testing.T) {
+	for name, dialect := range CsvDialectPresets {
+		err := dialect.Validate()
+		if err != nil {
+			t.Errorf("preset %s is invalid: %v", name, err)
+		}
+	}
+
+	invalid := map[string]CsvDialect{
+		"QuoteDelimiter":         {Delimiter: '"', DecimalSeparator: '.', TimeLayout: TimeLayout},
+		"UnknownSeparator":       {Delimiter: ';', DecimalSeparator: '/', TimeLayout: TimeLayout},
+		"SeparatorAsDelimiter":   {Delimiter: ',', DecimalSeparator: ',', TimeLayout: TimeLayout},
+		"TimeLayoutWithoutDate":  {Delimiter: ',', DecimalSeparator: '.', TimeLayout: "15:04:05"},
+		"TimeLayoutWithoutYear":  {Delimiter: ',', DecimalSeparator: '.', TimeLayout: "02.01 15:04:05"},
+		"TimeLayoutNotReference": {Delimiter: ',', DecimalSeparator: '.', TimeLayout: "dd.mm.yyyy"},
+	}
+	for name, dialect := range invalid {
+		err := dialect.Validate()
+		if err == nil {
+			t.Errorf("expected an error for %s", name)
+		}
+	}
+}
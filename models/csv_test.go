@@ -0,0 +1,287 @@
+package models
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCsvHeader = "TransactionId,RequestId,TerminalId,PartnerObjectId,AmountTotal,AmountOriginal,CommissionPS,CommissionClient,CommissionProvider,DateInput,DatePost,Status,PaymentType,PaymentNumber,ServiceId,Service,PayeeId,PayeeName,PayeeBankMfo,PayeeBankAccount,PaymentNarrative"
+
+func TestTransactionCsvReader_Read_QuotedFields(t *testing.T) {
+	csvData := testCsvHeader + "\n" +
+		"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,\"Поповнення, карток\",14232155,\"ТОВ \"\"Рога і копита\"\"\",254751,UA713451373919523,\"Перерахування коштів,\nзгідно договору\"\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedService := "Поповнення, карток"
+	if transaction.Service != expectedService {
+		t.Errorf("%v != %v", transaction.Service, expectedService)
+	}
+
+	expectedPayeeName := "ТОВ \"Рога і копита\""
+	if transaction.PayeeName != expectedPayeeName {
+		t.Errorf("%v != %v", transaction.PayeeName, expectedPayeeName)
+	}
+
+	expectedPaymentNarrative := "Перерахування коштів,\nзгідно договору"
+	if transaction.PaymentNarrative != expectedPaymentNarrative {
+		t.Errorf("%v != %v", transaction.PaymentNarrative, expectedPaymentNarrative)
+	}
+
+	_, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("expected %v, received %v", io.EOF, err)
+	}
+}
+
+func TestTransactionCsvReader_Read_CRLF(t *testing.T) {
+	csvData := testCsvHeader + "\r\n" +
+		"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів\r\n" +
+		"2,20030,3507,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 12:36:52,2022-08-12 15:36:53,declined,cash,PS16698215,13990,Поповнення карток,14332255,privat,255752,UA713461333619513,Перерахування коштів\r\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expectedId := range []uint64{1, 2} {
+		transaction, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if transaction.Id != expectedId {
+			t.Errorf("%v != %v", transaction.Id, expectedId)
+		}
+
+		expectedPaymentNarrative := "Перерахування коштів"
+		if transaction.PaymentNarrative != expectedPaymentNarrative {
+			t.Errorf("%q != %q", transaction.PaymentNarrative, expectedPaymentNarrative)
+		}
+	}
+}
+
+func TestTransactionCsvReader_Read_CRLFInQuotedField(t *testing.T) {
+	csvData := testCsvHeader + "\r\n" +
+		"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,\"рядок 1\r\nрядок 2\nрядок 3\"\r\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CRLF is normalized to LF by encoding/csv
+	expectedPaymentNarrative := "рядок 1\nрядок 2\nрядок 3"
+	if transaction.PaymentNarrative != expectedPaymentNarrative {
+		t.Errorf("%q != %q", transaction.PaymentNarrative, expectedPaymentNarrative)
+	}
+}
+
+func TestTransactionCsvReader_Read_InvalidFieldPosition(t *testing.T) {
+	csvData := testCsvHeader + "\n" +
+		"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,\"Поповнення\n карток\",14232155,pumb,254751,UA713451373919523,Перерахування коштів\n" +
+		"2,-20030,3507,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 12:36:52,2022-08-12 15:36:53,declined,cash,PS16698215,13990,Поповнення карток,14332255,privat,255752,UA713461333619513,Перерахування коштів\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	if err == nil {
+		t.Fatal("error is nil")
+	}
+
+	expectedPrefix := "line 4, column RequestId:"
+	if !strings.HasPrefix(err.Error(), expectedPrefix) {
+		t.Errorf("expected error starting with %q, received %q", expectedPrefix, err.Error())
+	}
+}
+
+func TestTransactionCsvReader_Read_WrongNumberOfFields(t *testing.T) {
+	csvData := testCsvHeader + "\n1,2,3\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	if err == nil {
+		t.Fatal("error is nil")
+	}
+
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error to contain the line number, received %q", err.Error())
+	}
+}
+
+func TestTransactionCsvReader_Read_LongLine(t *testing.T) {
+	narrative := strings.Repeat("a", 128*1024)
+	csvData := testCsvHeader + "\n" +
+		"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523," + narrative + "\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transaction.PaymentNarrative != narrative {
+		t.Errorf("expected narrative of length %d, received %d", len(narrative), len(transaction.PaymentNarrative))
+	}
+}
+
+func TestTransactionCsvWriter_Write_SpecialCharacters(t *testing.T) {
+	transaction := Transaction{
+		Id:               1,
+		DateInput:        time.Date(2022, time.August, 12, 11, 25, 27, 0, time.UTC),
+		DatePost:         time.Date(2022, time.August, 12, 14, 25, 27, 0, time.UTC),
+		Status:           ACCEPTED,
+		PaymentType:      CASH,
+		PaymentNumber:    "PS16698205",
+		Service:          "Поповнення, карток",
+		PayeeName:        "ТОВ \"Рога і копита\"",
+		PayeeBankAccount: "UA713451373919523",
+		PaymentNarrative: "Перерахування коштів,\nзгідно договору",
+	}
+	builder := strings.Builder{}
+	writer := NewTransactionCsvWriter(&builder)
+	err := writer.Write(transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "1,0,0,0,0.00,0.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,0,\"Поповнення, карток\",0,\"ТОВ \"\"Рога і копита\"\"\",0,UA713451373919523,\"Перерахування коштів,\nзгідно договору\"\n"
+	if builder.String() != expected {
+		t.Errorf("\n%v\n!=\n%v", expected, builder.String())
+	}
+}
+
+func TestTransactionCsvWriter_Write_CRLFInField(t *testing.T) {
+	transaction := Transaction{
+		Id:               1,
+		DateInput:        time.Date(2022, time.August, 12, 11, 25, 27, 0, time.UTC),
+		DatePost:         time.Date(2022, time.August, 12, 14, 25, 27, 0, time.UTC),
+		Status:           ACCEPTED,
+		PaymentType:      CASH,
+		PaymentNarrative: "рядок 1\r\nрядок 2",
+	}
+	builder := strings.Builder{}
+	writer := NewTransactionCsvWriter(&builder)
+	err := writer.Write(transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "1,0,0,0,0.00,0.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,,0,,0,,0,,\"рядок 1\r\nрядок 2\"\n"
+	if builder.String() != expected {
+		t.Errorf("%q != %q", builder.String(), expected)
+	}
+}
+
+func TestNewCsvHeaderMapping_ReorderedSnakeCaseColumns(t *testing.T) {
+	header := []string{
+		"payment_narrative", "payee_bank_account", "payee_bank_mfo", "payee_name", "payee_id", "service",
+		"service_id", "payment_number", "payment_type", "status", "date_post", "date_input",
+		"commission_provider", "commission_client", "commission_ps", "amount_original", "amount_total",
+		"partner_object_id", "terminal_id", "request_id", "transaction_id", "comment",
+	}
+	mapping, err := NewCsvHeaderMapping(header, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := []string{
+		"Перерахування коштів", "UA713451373919523", "254751", "pumb", "14232155", "Поповнення карток",
+		"13980", "PS16698205", "cash", "accepted", "2022-08-12 14:25:27", "2022-08-12 11:25:27",
+		"0.00", "0.00", "0.00", "1.00", "1.00",
+		"1111", "3506", "20020", "1", "some comment",
+	}
+	transaction, err := mapping.newTransaction(record, &DefaultCsvDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewTransactionFromCSVRow(
+		strings.Split(
+			"1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів",
+			",",
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transaction != expected {
+		t.Errorf("\n%+v\n!=\n%+v", transaction, expected)
+	}
+}
+
+func TestNewCsvHeaderMapping_RejectUnknownColumns(t *testing.T) {
+	header := append(strings.Split(testCsvHeader, ","), "Comment")
+	_, err := NewCsvHeaderMapping(header, true)
+	if err == nil {
+		t.Fatal("error is nil")
+	}
+
+	expected := "unknown columns: Comment"
+	if err.Error() != expected {
+		t.Errorf("expected %q, received %q", expected, err.Error())
+	}
+}
+
+func TestNewCsvHeaderMapping_MissingColumns(t *testing.T) {
+	header := strings.Split(testCsvHeader, ",")
+	_, err := NewCsvHeaderMapping(append(header[:17:17], header[19]), false)
+	if err == nil {
+		t.Fatal("error is nil")
+	}
+
+	expected := "missing required columns: PayeeName, PayeeBankMfo, PaymentNarrative"
+	if err.Error() != expected {
+		t.Errorf("expected %q, received %q", expected, err.Error())
+	}
+}
+
+func TestNewCsvHeaderMapping_DuplicateColumn(t *testing.T) {
+	header := append(strings.Split(testCsvHeader, ","), "transaction_id")
+	_, err := NewCsvHeaderMapping(header, false)
+	if err == nil {
+		t.Error("error is nil")
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Import describes a file uploaded to the database. Transactions created or
+// last updated by the upload refer to it, so that the upload can be reverted.
+type Import struct {
+	Id       uint64 `gorm:"primaryKey" json:"id"`
+	FileName string `json:"file_name"`
+
+	// Checksum is the hex encoded SHA-256 hash of the uploaded CSV data.
+	// Compressed files are hashed after decompression, files of an archive
+	// are hashed in the order they are stored in the archive.
+	Checksum      string     `gorm:"size:64;index" json:"checksum"`
+	Uploader      string     `json:"uploader"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	RevertedAt    *time.Time `json:"reverted_at"`
+	RowCount      int        `json:"row_count"`
+	InsertedCount int        `json:"inserted_count"`
+	SkippedCount  int        `json:"skipped_count"`
+	UpdatedCount  int        `json:"updated_count"`
+	RejectedCount int        `json:"rejected_count"`
+
+	// Files contains the results of the files from an uploaded archive.
+	Files []ImportedFile `gorm:"type:text;serializer:json" json:"files,omitempty"`
+}
+
+// ImportedFile describes a single CSV file of an uploaded archive.
+type ImportedFile struct {
+	Name          string `json:"name"`
+	RowCount      int    `json:"row_count"`
+	InsertedCount int    `json:"inserted_count"`
+	SkippedCount  int    `json:"skipped_count"`
+	UpdatedCount  int    `json:"updated_count"`
+	RejectedCount int    `json:"rejected_count"`
+}
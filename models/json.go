@@ -0,0 +1,374 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TransactionReader reads transactions from an uploaded file.
+type TransactionReader interface {
+	// ReadHeader prepares reading of the transactions. Unknown columns
+	// or fields of the data are rejected if rejectUnknownColumns is true.
+	ReadHeader(rejectUnknownColumns bool) error
+
+	// Read returns the next transaction or io.EOF if there are no more transactions.
+	// If the transaction is invalid, RowErrors is returned and the reading may be
+	// continued with the next transaction.
+	Read() (Transaction, error)
+
+	// Header returns the column names of the values returned by Record.
+	Header() []string
+
+	// Record returns raw values of the transaction returned by the last call to Read.
+	Record() []string
+}
+
+// jsonOutputFields are written to the JSON output, but they are ignored on input.
+var jsonOutputFields = map[string]bool{"import_id": true, "payee_bank_name": true}
+
+// transactionJsonTimeColumns also accept time in the RFC 3339 format used in JSON output.
+var transactionJsonTimeColumns = map[string]bool{"DateInput": true, "DatePost": true}
+
+var (
+	// transactionJsonFields contains names of the JSON fields
+	// in the order of the columns in transactionColumns
+	transactionJsonFields = newTransactionJsonFields()
+
+	// transactionColumnIndexes maps normalized names of the
+	// columns and the JSON fields to the indexes of the columns
+	transactionColumnIndexes = newTransactionColumnIndexes()
+)
+
+func newTransactionJsonFields() []string {
+	fieldNames := map[string]string{}
+	transactionType := reflect.TypeOf(Transaction{})
+	for i := 0; i < transactionType.NumField(); i++ {
+		name := strings.Split(transactionType.Field(i).Tag.Get("json"), ",")[0]
+		fieldNames[NormalizeCsvColumnName(name)] = name
+	}
+
+	names := make([]string, len(transactionColumns))
+	for i, column := range transactionColumns {
+		names[i] = fieldNames[NormalizeCsvColumnName(column.name)]
+	}
+
+	return names
+}
+
+func newTransactionColumnIndexes() map[string]int {
+	indexes := map[string]int{}
+	for i, column := range transactionColumns {
+		indexes[NormalizeCsvColumnName(column.name)] = i
+		indexes[NormalizeCsvColumnName(transactionJsonFields[i])] = i
+	}
+
+	return indexes
+}
+
+// TransactionJsonReader reads transactions either from a JSON array or from NDJSON
+// data with a single transaction per line. Fields of the transactions have the same
+// names as in the JSON output. Values are validated the same way as CSV fields, and
+// both JSON numbers and strings are accepted for numeric fields.
+type TransactionJsonReader struct {
+	array         bool
+	rejectUnknown bool
+	record        []string
+
+	// decoder and lines are used for JSON arrays
+	decoder *json.Decoder
+	lines   *lineCounter
+
+	// reader and line are used for NDJSON data
+	reader *bufio.Reader
+	line   int
+}
+
+// NewTransactionJsonReader creates a reader of a JSON array if array
+// is true, otherwise transactions are read from NDJSON data.
+func NewTransactionJsonReader(r io.Reader, array bool) *TransactionJsonReader {
+	reader := &TransactionJsonReader{array: array}
+	if array {
+		reader.lines = &lineCounter{reader: r}
+		reader.decoder = json.NewDecoder(reader.lines)
+	} else {
+		reader.reader = bufio.NewReader(r)
+	}
+
+	return reader
+}
+
+// ReadHeader reads the beginning of the JSON array, io.EOF is returned if there is no data.
+func (r *TransactionJsonReader) ReadHeader(rejectUnknownColumns bool) error {
+	r.rejectUnknown = rejectUnknownColumns
+	if !r.array {
+		return nil
+	}
+
+	token, err := r.decoder.Token()
+	if err != nil {
+		return r.wrapDecodeError(err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return RowErrors{{Line: 1, Reason: "the data should be a JSON array of transactions"}}
+	}
+
+	return nil
+}
+
+// Read returns RowErrors for each invalid transaction. In a JSON array, a syntax
+// error stops the reading, because the following transactions cannot be found.
+func (r *TransactionJsonReader) Read() (Transaction, error) {
+	r.record = nil
+	data, line, err := r.readObject()
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	return r.newTransaction(data, line)
+}
+
+// Header returns TransactionCsvHeader, the fields of the transactions
+// are returned by Record in the same order as in CSV files.
+func (r *TransactionJsonReader) Header() []string {
+	return TransactionCsvHeader
+}
+
+func (r *TransactionJsonReader) Record() []string {
+	return r.record
+}
+
+func (r *TransactionJsonReader) readObject() (json.RawMessage, int, error) {
+	if !r.array {
+		return r.readLine()
+	}
+
+	if !r.decoder.More() {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, 0, r.wrapDecodeError(err)
+		}
+
+		if delim, ok := token.(json.Delim); !ok || delim != ']' {
+			return nil, 0, fmt.Errorf("invalid JSON data: unexpected %v", token)
+		}
+
+		return nil, 0, io.EOF
+	}
+
+	var data json.RawMessage
+	err := r.decoder.Decode(&data)
+	if err != nil {
+		return nil, 0, r.wrapDecodeError(err)
+	}
+
+	return data, r.lines.lineAt(r.decoder.InputOffset() - int64(len(data))), nil
+}
+
+// readLine returns the next line which is not empty.
+func (r *TransactionJsonReader) readLine() (json.RawMessage, int, error) {
+	for {
+		data, err := r.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(data) == 0) {
+			return nil, 0, err
+		}
+
+		r.line++
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			return data, r.line, nil
+		}
+	}
+}
+
+func (r *TransactionJsonReader) newTransaction(data json.RawMessage, line int) (Transaction, error) {
+	if !utf8.Valid(data) {
+		// invalid characters would be replaced by json.Unmarshal silently
+		return Transaction{}, RowErrors{{Line: line, Reason: errInvalidUtf8.Error()}}
+	}
+
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return Transaction{}, RowErrors{{Line: line, Reason: fmt.Sprintf("invalid transaction object: %v", err)}}
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var rowErrors RowErrors
+	fieldNames := make([]string, len(transactionColumns))
+	record := make([]string, len(transactionColumns))
+	for _, name := range names {
+		index, ok := transactionColumnIndexes[NormalizeCsvColumnName(name)]
+		if !ok {
+			if r.rejectUnknown && !jsonOutputFields[name] {
+				rowErrors = append(rowErrors, RowError{Line: line, Column: name, Reason: "unknown field"})
+			}
+
+			continue
+		}
+
+		value, err := jsonFieldValue(transactionColumns[index].name, fields[name])
+		if err != nil {
+			fieldNames[index] = name
+			rowErrors = append(rowErrors, RowError{Line: line, Column: name, Reason: err.Error()})
+			continue
+		}
+
+		if value != nil {
+			fieldNames[index] = name
+			record[index] = *value
+		}
+	}
+
+	r.record = record
+	for i, name := range fieldNames {
+		if name == "" {
+			rowErrors = append(rowErrors, RowError{Line: line, Column: transactionJsonFields[i], Reason: "missing field"})
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		return Transaction{}, rowErrors
+	}
+
+	transaction, err := defaultCsvHeaderMapping.newTransaction(record, &DefaultCsvDialect)
+	if err != nil {
+		var fieldErrors FieldErrors
+		if !errors.As(err, &fieldErrors) {
+			return Transaction{}, err
+		}
+
+		rowErrors = make(RowErrors, len(fieldErrors))
+		for i, fieldErr := range fieldErrors {
+			rowErrors[i] = RowError{
+				Line:   line,
+				Column: fieldNames[fieldErr.Index],
+				Value:  fieldErr.Value,
+				Reason: fieldErr.Err.Error(),
+			}
+		}
+
+		return Transaction{}, rowErrors
+	}
+
+	return transaction, nil
+}
+
+// jsonFieldValue returns the value of the field as it would be written in CSV data,
+// nil is returned for null values, so that they are treated as missing fields.
+func jsonFieldValue(column string, data json.RawMessage) (*string, error) {
+	var value string
+	switch data[0] {
+	case 'n':
+		return nil, nil
+	case '"':
+		err := json.Unmarshal(data, &value)
+		if err != nil {
+			return nil, err
+		}
+	case '{', '[':
+		return nil, errors.New("the value should be a string or a number")
+	default:
+		value = string(data)
+	}
+
+	if transactionJsonTimeColumns[column] {
+		timeValue, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			value = timeValue.UTC().Format(TimeLayout)
+		}
+	}
+
+	return &value, nil
+}
+
+func (r *TransactionJsonReader) wrapDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("invalid JSON data in line %d: %v", r.lines.lineAt(syntaxErr.Offset), syntaxErr)
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		return errors.New("invalid JSON data: unexpected end of the data")
+	}
+
+	return err
+}
+
+// lineCounter counts lines of the data read from the reader. Offsets of the line
+// breaks are kept until a greater offset is passed to lineAt.
+type lineCounter struct {
+	reader     io.Reader
+	offset     int64
+	line       int
+	lineBreaks []int64
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.lineBreaks = append(c.lineBreaks, c.offset+int64(i))
+		}
+	}
+
+	c.offset += int64(n)
+	return n, err
+}
+
+// lineAt returns the number of the line at the offset, offsets should not decrease.
+func (c *lineCounter) lineAt(offset int64) int {
+	passed := 0
+	for passed < len(c.lineBreaks) && c.lineBreaks[passed] < offset {
+		passed++
+	}
+
+	c.line += passed
+	c.lineBreaks = c.lineBreaks[passed:]
+	return c.line + 1
+}
+
+// TransactionNdjsonWriter writes transactions as NDJSON data with a single
+// transaction per line. The transactions have the same fields as in the JSON output.
+type TransactionNdjsonWriter struct {
+	writer     *bufio.Writer
+	encoder    *json.Encoder
+	projection TransactionProjection
+}
+
+func NewTransactionNdjsonWriter(w io.Writer) *TransactionNdjsonWriter {
+	writer := bufio.NewWriter(w)
+	return &TransactionNdjsonWriter{writer: writer, encoder: json.NewEncoder(writer)}
+}
+
+// SetProjection selects the fields to be written in the order of the projection.
+func (w *TransactionNdjsonWriter) SetProjection(projection TransactionProjection) {
+	w.projection = projection
+}
+
+func (w *TransactionNdjsonWriter) Write(transaction Transaction) error {
+	if w.projection == nil {
+		return w.encoder.Encode(transaction)
+	}
+
+	return w.encoder.Encode(ProjectedTransaction{Transaction: &transaction, Projection: w.projection})
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *TransactionNdjsonWriter) Flush() error {
+	return w.writer.Flush()
+}
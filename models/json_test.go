@@ -0,0 +1,241 @@
+package models
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionJsonReader_Read_JsonOutput(t *testing.T) {
+	expected, err := NewTransactionFromCSVRow(
+		strings.Split(
+			"1,20020,3506,1111,1.00,1.00,0.00,0.00,-0.01,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів",
+			",",
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importId := uint64(5)
+	expected.ImportId = &importId
+	data, err := json.MarshalIndent([]Transaction{expected, expected}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewTransactionJsonReader(strings.NewReader(string(data)), true)
+	err = reader.ReadHeader(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the import id is ignored
+	expected.ImportId = nil
+	for i := 0; i < 2; i++ {
+		transaction, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(transaction, expected) {
+			t.Errorf("expected %+v, received %+v", expected, transaction)
+		}
+	}
+
+	_, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("expected %v, received %v", io.EOF, err)
+	}
+}
+
+func TestTransactionJsonReader_Read_ArrayErrorLines(t *testing.T) {
+	jsonData := "[\n" +
+		"  " + testJsonTransaction(1) + ",\n" +
+		"  {\"transaction_id\": 2},\n" +
+		"  " + strings.Replace(testJsonTransaction(3), `"status":"accepted"`, `"status":"unknown"`, 1) + "\n" +
+		"]"
+	reader := NewTransactionJsonReader(strings.NewReader(jsonData), true)
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	rowErrors, ok := err.(RowErrors)
+	if !ok || len(rowErrors) != numberOfTransactionFields-1 {
+		t.Fatalf("expected %d errors of missing fields, received %v", numberOfTransactionFields-1, err)
+	}
+
+	if rowErrors[0].Line != 3 || rowErrors[0].Column != "request_id" || rowErrors[0].Reason != "missing field" {
+		t.Errorf("expected missing request_id in line 3, received %+v", rowErrors[0])
+	}
+
+	_, err = reader.Read()
+	rowErrors, ok = err.(RowErrors)
+	if !ok || len(rowErrors) != 1 || rowErrors[0].Line != 4 || rowErrors[0].Column != "status" {
+		t.Errorf("expected an error of status in line 4, received %v", err)
+	}
+
+	if reader.Record()[0] != "3" {
+		t.Errorf("expected the record of transaction 3, received %q", reader.Record())
+	}
+
+	_, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("expected %v, received %v", io.EOF, err)
+	}
+}
+
+func TestTransactionJsonReader_Read_ArraySyntaxError(t *testing.T) {
+	reader := NewTransactionJsonReader(strings.NewReader("[\n"+testJsonTransaction(1)+",\n{\"transaction_id\": }]"), true)
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	if _, ok := err.(RowErrors); ok || err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected a syntax error in line 3, received %v", err)
+	}
+}
+
+func TestTransactionJsonReader_ReadHeader_NotArray(t *testing.T) {
+	reader := NewTransactionJsonReader(strings.NewReader(testJsonTransaction(1)), true)
+	err := reader.ReadHeader(false)
+	if _, ok := err.(RowErrors); !ok {
+		t.Errorf("expected RowErrors, received %v", err)
+	}
+}
+
+func TestTransactionJsonReader_Read_Ndjson(t *testing.T) {
+	jsonData := testJsonTransaction(1) + "\n" +
+		"\n" +
+		"{\"transaction_id\": 2,\n" +
+		strings.Replace(testJsonTransaction(3), `"date_post":"2022-08-12 14:25:27"`, `"date_post":"2022-08-12T17:25:27+03:00"`, 1) + "\n"
+	reader := NewTransactionJsonReader(strings.NewReader(jsonData), false)
+	err := reader.ReadHeader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction, err := reader.Read()
+	if err != nil || transaction.Id != 1 {
+		t.Fatalf("expected transaction 1, received %+v, %v", transaction, err)
+	}
+
+	// the invalid line is skipped
+	_, err = reader.Read()
+	rowErrors, ok := err.(RowErrors)
+	if !ok || len(rowErrors) != 1 || rowErrors[0].Line != 3 {
+		t.Errorf("expected a single error in line 3, received %v", err)
+	}
+
+	transaction, err = reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDatePost := time.Date(2022, 8, 12, 14, 25, 27, 0, time.UTC)
+	if transaction.Id != 3 || !transaction.DatePost.Equal(expectedDatePost) {
+		t.Errorf("expected transaction 3 posted at %v, received %+v", expectedDatePost, transaction)
+	}
+
+	_, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("expected %v, received %v", io.EOF, err)
+	}
+}
+
+func TestTransactionJsonReader_Read_UnknownFields(t *testing.T) {
+	jsonData := strings.Replace(testJsonTransaction(1), "{", `{"comment":"x","import_id":3,`, 1)
+	reader := NewTransactionJsonReader(strings.NewReader(jsonData), false)
+	err := reader.ReadHeader(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	rowErrors, ok := err.(RowErrors)
+	if !ok || len(rowErrors) != 1 || rowErrors[0].Column != "comment" {
+		t.Errorf("expected an error of the unknown field, received %v", err)
+	}
+}
+
+// testJsonTransaction returns a transaction object with numeric
+// fields written both as JSON numbers and as strings.
+func TestTransactionNdjsonWriter_Write_RoundTrip(t *testing.T) {
+	var expected []Transaction
+	for _, id := range []int{1, 2} {
+		transaction, err := NewTransactionJsonReader(strings.NewReader(testJsonTransaction(id)), false).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected = append(expected, transaction)
+	}
+
+	builder := strings.Builder{}
+	writer := NewTransactionNdjsonWriter(&builder)
+	for _, transaction := range expected {
+		err := writer.Write(transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the transactions which were not uploaded have no import
+	if strings.Contains(builder.String(), "import_id") {
+		t.Errorf("unexpected import_id in %s", builder.String())
+	}
+
+	reader := NewTransactionJsonReader(strings.NewReader(builder.String()), false)
+	for _, transaction := range expected {
+		actual, err := reader.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(transaction, actual) {
+			t.Errorf("%+v != %+v", transaction, actual)
+		}
+	}
+
+	_, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("%v != %v", io.EOF, err)
+	}
+}
+
+func testJsonTransaction(id int) string {
+	return strings.Replace(
+		`{"transaction_id":ID,"request_id":20020,"terminal_id":"3506","partner_object_id":1111,`+
+			`"amount_total":1,"amount_original":1.00,"commission_ps":0,"commission_client":0,"commission_provider":-0.01,`+
+			`"date_input":"2022-08-12 11:25:27","date_post":"2022-08-12 14:25:27","status":"accepted",`+
+			`"payment_type":"cash","payment_number":"PS16698205","service_id":13980,"service":"Поповнення карток",`+
+			`"payee_id":14232155,"payee_name":"pumb","payee_bank_mfo":254751,"payee_bank_account":"UA713451373919523",`+
+			`"payment_narrative":"Перерахування коштів"}`,
+		"ID",
+		strconv.Itoa(id),
+		1,
+	)
+}
@@ -8,6 +8,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// legacyPayeeBankAccountCheck is the name gorm gave to the check of the bank account
+// before the Ukrainian IBANs were accepted.
+const legacyPayeeBankAccountCheck = "chk_transactions_payee_bank_account"
+
 func MigrateAll(db *gorm.DB) error {
 	return db.Transaction(migrateAll)
 }
@@ -28,7 +32,23 @@ func migrateAll(tx *gorm.DB) error {
 		return err
 	}
 
-	return tx.AutoMigrate(&Transaction{})
+	err = tx.AutoMigrate(&Import{}, &Transaction{}, &TransactionSnapshot{}, &UploadJob{})
+	if err != nil {
+		return err
+	}
+
+	return dropLegacyPayeeBankAccountCheck(tx)
+}
+
+// dropLegacyPayeeBankAccountCheck drops the unanchored check of the bank account,
+// which was replaced by the check accepting both the legacy accounts and the IBANs.
+func dropLegacyPayeeBankAccountCheck(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasConstraint(&Transaction{}, legacyPayeeBankAccountCheck) {
+		return nil
+	}
+
+	return migrator.DropConstraint(&Transaction{}, legacyPayeeBankAccountCheck)
 }
 
 func createEnumIfNotExists(tx *gorm.DB, schema, typeName string, values []string) error {
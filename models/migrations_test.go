@@ -133,7 +133,10 @@ func Test_migrateAll(t *testing.T) {
 	}
 
 	defer func() {
+		log.Println(db.Exec("DROP TABLE rest_api.transaction_snapshots").Error)
 		log.Println(db.Exec("DROP TABLE rest_api.transactions").Error)
+		log.Println(db.Exec("DROP TABLE rest_api.upload_jobs").Error)
+		log.Println(db.Exec("DROP TABLE rest_api.imports").Error)
 		log.Println(db.Exec("DROP TYPE rest_api.status_type").Error)
 		log.Println(db.Exec("DROP TYPE rest_api.payment_type_type").Error)
 		log.Println(db.Exec("DROP SCHEMA rest_api").Error)
@@ -142,6 +145,10 @@ func Test_migrateAll(t *testing.T) {
 	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_type WHERE typname = '%s'", "status_type"))
 	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_type WHERE typname = '%s'", "payment_type_type"))
 	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_tables WHERE tablename = '%s'", "transactions"))
+	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_tables WHERE tablename = '%s'", "upload_jobs"))
+	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_tables WHERE tablename = '%s'", "imports"))
+	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_tables WHERE tablename = '%s'", "transaction_snapshots"))
+	ensureEntityExists(t, db, fmt.Sprintf("SELECT count(*) FROM pg_constraint WHERE conname = '%s'", "chk_transactions_payee_bank_account_format"))
 }
 
 func ensureEntityExists(t *testing.T, db *gorm.DB, sql string) {
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	// parquetRowGroupRows is the number of rows kept in memory
+	// before they are written to the file as a row group
+	parquetRowGroupRows = 50000
+
+	// the amounts are written as decimals with 2 digits after the point
+	parquetAmountScale     = 2
+	parquetAmountPrecision = 18
+)
+
+// Tags of parquet-go which define the types of the columns, the name of a column is added to them.
+// The tags set the legacy converted types, parquet-go derives the logical types from them,
+// so both are written for the older and the newer readers.
+const (
+	parquetInt32Tag     = "type=INT32"
+	parquetInt64Tag     = "type=INT64"
+	parquetTimestampTag = "type=INT64, convertedtype=TIMESTAMP_MICROS, isadjustedtoutc=true"
+	parquetStringTag    = "type=BYTE_ARRAY, convertedtype=UTF8"
+
+	// parquetDictionaryTag is used for the string columns with few distinct values
+	parquetDictionaryTag = parquetStringTag + ", encoding=PLAIN_DICTIONARY"
+)
+
+// parquetAmountTag describes the decimals written by parquetAmountColumn.
+var parquetAmountTag = fmt.Sprintf(
+	"type=INT64, convertedtype=DECIMAL, scale=%d, precision=%d", parquetAmountScale, parquetAmountPrecision,
+)
+
+// parquetColumn describes how the values of a transaction column are written.
+type parquetColumn struct {
+	tag string
+
+	// value returns an int32, int64 or string value of the column
+	value func(t *Transaction) (interface{}, error)
+}
+
+// parquetInt64Column writes the id as a signed integer, as the ids are stored as bigint
+// in the database. The ids which do not fit it are rejected instead of being wrapped around.
+func parquetInt64Column(id func(t *Transaction) uint64) parquetColumn {
+	return parquetColumn{
+		tag: parquetInt64Tag,
+		value: func(t *Transaction) (interface{}, error) {
+			value := id(t)
+			if value > math.MaxInt64 {
+				return nil, fmt.Errorf("the id %d does not fit the signed 64-bit integer of the Parquet column", value)
+			}
+
+			return int64(value), nil
+		},
+	}
+}
+
+func parquetInt32Column(value func(t *Transaction) int32) parquetColumn {
+	return parquetColumn{
+		tag:   parquetInt32Tag,
+		value: func(t *Transaction) (interface{}, error) { return value(t), nil },
+	}
+}
+
+func parquetAmountColumn(amount func(t *Transaction) float32) parquetColumn {
+	return parquetColumn{
+		tag: parquetAmountTag,
+		value: func(t *Transaction) (interface{}, error) {
+			return int64(math.Round(float64(amount(t)) * math.Pow10(parquetAmountScale))), nil
+		},
+	}
+}
+
+func parquetStringColumn(tag string, text func(t *Transaction) string) parquetColumn {
+	return parquetColumn{
+		tag:   tag,
+		value: func(t *Transaction) (interface{}, error) { return text(t), nil },
+	}
+}
+
+// transactionParquetColumns contains the columns in the order of transactionColumns.
+var transactionParquetColumns = []parquetColumn{
+	parquetInt64Column(func(t *Transaction) uint64 { return t.Id }),
+	parquetInt64Column(func(t *Transaction) uint64 { return t.RequestId }),
+	parquetInt64Column(func(t *Transaction) uint64 { return t.TerminalId }),
+	parquetInt32Column(func(t *Transaction) int32 { return int32(t.PartnerObjectId) }),
+	parquetAmountColumn(func(t *Transaction) float32 { return t.AmountTotal }),
+	parquetAmountColumn(func(t *Transaction) float32 { return t.AmountOriginal }),
+	parquetAmountColumn(func(t *Transaction) float32 { return t.CommissionPS }),
+	parquetAmountColumn(func(t *Transaction) float32 { return t.CommissionClient }),
+	parquetAmountColumn(func(t *Transaction) float32 { return t.CommissionProvider }),
+	{
+		tag:   parquetTimestampTag,
+		value: func(t *Transaction) (interface{}, error) { return t.DateInput.UnixMicro(), nil },
+	},
+	{
+		tag:   parquetTimestampTag,
+		value: func(t *Transaction) (interface{}, error) { return t.DatePost.UnixMicro(), nil },
+	},
+	parquetStringColumn(parquetDictionaryTag, func(t *Transaction) string { return string(t.Status) }),
+	parquetStringColumn(parquetDictionaryTag, func(t *Transaction) string { return string(t.PaymentType) }),
+	parquetStringColumn(parquetStringTag, func(t *Transaction) string { return t.PaymentNumber }),
+	parquetInt64Column(func(t *Transaction) uint64 { return t.ServiceId }),
+	parquetStringColumn(parquetDictionaryTag, func(t *Transaction) string { return t.Service }),
+	parquetInt64Column(func(t *Transaction) uint64 { return t.PayeeId }),
+	parquetStringColumn(parquetStringTag, func(t *Transaction) string { return t.PayeeName }),
+	// the bank MFO has 6 digits, so it fits a signed integer
+	parquetInt32Column(func(t *Transaction) int32 { return int32(t.PayeeBankMfo) }),
+	parquetStringColumn(parquetStringTag, func(t *Transaction) string { return t.PayeeBankAccount }),
+	parquetStringColumn(parquetStringTag, func(t *Transaction) string { return t.PaymentNarrative }),
+}
+
+// TransactionParquetWriter writes transactions to a Parquet file with parquet-go. The amounts
+// are written as decimals, the dates as timestamps, and the columns with few distinct values,
+// which are Status, PaymentType and Service, are dictionary encoded. The pages are compressed
+// with gzip. The rows are buffered and written to the underlying io.Writer in row groups of
+// parquetRowGroupRows rows, so only one row group is kept in memory.
+type TransactionParquetWriter struct {
+	w          io.Writer
+	writer     *writer.CSVWriter
+	projection TransactionProjection
+
+	// columns contains indexes of the written columns in transactionColumns
+	columns []int
+
+	// rowCount is the number of buffered rows
+	rowCount     int
+	rowGroupRows int
+}
+
+func NewTransactionParquetWriter(w io.Writer) *TransactionParquetWriter {
+	return &TransactionParquetWriter{w: w, rowGroupRows: parquetRowGroupRows}
+}
+
+// SetProjection selects the columns to be written in the order of the
+// projection. It should be called before WriteHeader.
+func (w *TransactionParquetWriter) SetProjection(projection TransactionProjection) {
+	w.projection = projection
+}
+
+// WriteHeader writes the magic number which starts the file. It should be called before Write.
+func (w *TransactionParquetWriter) WriteHeader() error {
+	w.columns = w.projection
+	if w.columns == nil {
+		w.columns = make([]int, len(transactionColumns))
+		for i := range w.columns {
+			w.columns[i] = i
+		}
+	}
+
+	header := w.projection.Header()
+	metadata := make([]string, len(w.columns))
+	for i, index := range w.columns {
+		metadata[i] = "name=" + header[i] + ", " + transactionParquetColumns[index].tag
+	}
+
+	var err error
+	w.writer, err = writer.NewCSVWriterFromWriter(metadata, w.w, 1)
+	if err != nil {
+		return err
+	}
+
+	w.writer.CompressionType = parquet.CompressionCodec_GZIP
+	return nil
+}
+
+// Write buffers the transaction. An error is returned if a value does not fit its column,
+// the transaction is not written then.
+func (w *TransactionParquetWriter) Write(transaction Transaction) error {
+	values := make([]interface{}, len(w.columns))
+	for i, index := range w.columns {
+		value, err := transactionParquetColumns[index].value(&transaction)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %v", transaction.Id, err)
+		}
+
+		values[i] = value
+	}
+
+	err := w.writer.Write(values)
+	if err != nil {
+		return err
+	}
+
+	w.rowCount++
+	if w.rowCount < w.rowGroupRows {
+		return nil
+	}
+
+	w.rowCount = 0
+	return w.writer.Flush(true)
+}
+
+// Close writes the buffered rows and the footer of the file, which contains
+// the schema and the row groups. It does not close the underlying io.Writer.
+func (w *TransactionParquetWriter) Close() error {
+	return w.writer.WriteStop()
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestTransactionParquetWriter_Write(t *testing.T) {
+	datePost := time.Date(2022, 8, 12, 11, 25, 27, 0, time.UTC)
+	transactions := []Transaction{
+		{Id: 1, AmountTotal: 1, CommissionProvider: -0.01, DatePost: datePost, Status: ACCEPTED, Service: "Поповнення карток"},
+		{Id: 2, AmountTotal: 12.3, DatePost: datePost.Add(time.Second), Status: DECLINED, Service: "Поповнення карток"},
+		{Id: 3, AmountTotal: 100.45, DatePost: datePost.Add(time.Minute), Status: ACCEPTED, Service: "Оплата"},
+	}
+
+	writer := NewTransactionParquetWriter(nil)
+	metadata, columns := writeTestParquet(t, writer, nil, transactions...)
+	if metadata.NumRows != 3 {
+		t.Errorf("%v != %v", metadata.NumRows, 3)
+	}
+
+	schema := metadata.Schema
+	if len(schema) != len(transactionColumns)+1 {
+		t.Fatalf("%v != %v", len(schema), len(transactionColumns)+1)
+	}
+
+	amountTotal := schema[5]
+	decimal := amountTotal.GetLogicalType().GetDECIMAL()
+	if amountTotal.Name != "AmountTotal" || decimal == nil ||
+		decimal.Scale != parquetAmountScale || decimal.Precision != parquetAmountPrecision ||
+		amountTotal.GetConvertedType() != parquet.ConvertedType_DECIMAL ||
+		amountTotal.GetScale() != parquetAmountScale || amountTotal.GetPrecision() != parquetAmountPrecision {
+		t.Errorf("unexpected schema element: %v", amountTotal)
+	}
+
+	timestamp := schema[11].GetLogicalType().GetTIMESTAMP()
+	if schema[11].Name != "DatePost" || timestamp == nil || !timestamp.IsAdjustedToUTC || !timestamp.Unit.IsSetMICROS() ||
+		schema[11].GetConvertedType() != parquet.ConvertedType_TIMESTAMP_MICROS {
+		t.Errorf("unexpected schema element: %v", schema[11])
+	}
+
+	if schema[12].GetLogicalType().GetSTRING() == nil {
+		t.Errorf("unexpected schema element: %v", schema[12])
+	}
+
+	status := metadata.RowGroups[0].Columns[11].MetaData
+	if status.PathInSchema[0] != "Status" || status.DictionaryPageOffset == nil {
+		t.Errorf("expected the dictionary page of %v", status.PathInSchema)
+	}
+
+	if status.Codec != parquet.CompressionCodec_GZIP {
+		t.Errorf("%v != %v", status.Codec, parquet.CompressionCodec_GZIP)
+	}
+
+	expectedColumns := map[string][]interface{}{
+		"TransactionId":      {int64(1), int64(2), int64(3)},
+		"AmountTotal":        {int64(100), int64(1230), int64(10045)},
+		"CommissionProvider": {int64(-1), int64(0), int64(0)},
+		"DatePost":           {datePost.UnixMicro(), datePost.Add(time.Second).UnixMicro(), datePost.Add(time.Minute).UnixMicro()},
+		"Status":             {"accepted", "declined", "accepted"},
+		"Service":            {"Поповнення карток", "Поповнення карток", "Оплата"},
+		"PaymentNarrative":   {"", "", ""},
+	}
+	for name, expected := range expectedColumns {
+		if !reflect.DeepEqual(columns[name], expected) {
+			t.Errorf("%s: %v != %v", name, columns[name], expected)
+		}
+	}
+}
+
+func TestTransactionParquetWriter_Write_RowGroups(t *testing.T) {
+	projection, err := ParseTransactionProjection("status,transaction_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := NewTransactionParquetWriter(nil)
+	writer.rowGroupRows = 2
+	metadata, columns := writeTestParquet(
+		t, writer, projection,
+		Transaction{Id: 1, Status: ACCEPTED},
+		Transaction{Id: 2, Status: ACCEPTED},
+		Transaction{Id: 3, Status: DECLINED},
+	)
+	if len(metadata.RowGroups) != 2 {
+		t.Errorf("%v != %v", len(metadata.RowGroups), 2)
+	}
+
+	expectedColumns := map[string][]interface{}{
+		"Status":        {"accepted", "accepted", "declined"},
+		"TransactionId": {int64(1), int64(2), int64(3)},
+	}
+	if !reflect.DeepEqual(columns, expectedColumns) {
+		t.Errorf("%v != %v", columns, expectedColumns)
+	}
+}
+
+func TestTransactionParquetWriter_Write_Empty(t *testing.T) {
+	metadata, columns := writeTestParquet(t, NewTransactionParquetWriter(nil), nil)
+	if metadata.NumRows != 0 || len(metadata.RowGroups) != 0 || len(columns) != 0 {
+		t.Errorf("expected the file without rows, actual %v", metadata)
+	}
+}
+
+func TestTransactionParquetWriter_Write_IdOverflow(t *testing.T) {
+	writer := NewTransactionParquetWriter(&bytes.Buffer{})
+	err := writer.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Write(Transaction{Id: 1, PayeeId: math.MaxInt64 + 1})
+	if err == nil || !strings.Contains(err.Error(), "9223372036854775808") {
+		t.Errorf("expected the overflow error, actual %v", err)
+	}
+
+	err = writer.Write(Transaction{Id: math.MaxInt64})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// writeTestParquet writes the transactions and reads the file back with the reader of parquet-go.
+// The metadata of the file and the values of the columns by their names are returned.
+func writeTestParquet(
+	t *testing.T, writer *TransactionParquetWriter, projection TransactionProjection, transactions ...Transaction,
+) (*parquet.FileMetaData, map[string][]interface{}) {
+	data := bytes.Buffer{}
+	writer.w = &data
+	writer.SetProjection(projection)
+	err := writer.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transaction := range transactions {
+		err = writer.Write(transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(data.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parquetReader, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer parquetReader.ReadStop()
+	columns := map[string][]interface{}{}
+	rowCount := parquetReader.GetNumRows()
+	for i, element := range parquetReader.Footer.Schema[1:] {
+		if rowCount == 0 {
+			break
+		}
+
+		values, _, _, err := parquetReader.ReadColumnByIndex(int64(i), rowCount)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		columns[element.Name] = values
+	}
+
+	return parquetReader.Footer, columns
+}
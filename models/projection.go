@@ -0,0 +1,242 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// transactionStructFields contains names of the Transaction fields
+// in the order of the columns in transactionColumns
+var transactionStructFields = newTransactionStructFields()
+
+func newTransactionStructFields() []string {
+	names := make([]string, len(transactionColumns))
+	transactionType := reflect.TypeOf(Transaction{})
+	for i, field := range transactionProjectedFields {
+		names[i] = transactionType.Field(field.index).Name
+	}
+
+	return names
+}
+
+// TransactionProjection contains indexes of the transaction columns selected
+// for the output in the requested order. The nil projection selects all columns.
+type TransactionProjection []int
+
+// ParseTransactionProjection parses a comma-separated list of the column names, which
+// are matched the same way as CSV header names, so both the CSV column names and
+// the JSON field names are accepted. The empty list selects all columns.
+func ParseTransactionProjection(value string) (TransactionProjection, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var unknown []string
+	projection := TransactionProjection{}
+	selected := map[int]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, errors.New("empty field name")
+		}
+
+		index, ok := transactionColumnIndexes[NormalizeCsvColumnName(name)]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if selected[index] {
+			return nil, fmt.Errorf("duplicate field: %s", name)
+		}
+
+		selected[index] = true
+		projection = append(projection, index)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf(
+			"unknown fields: %s, the fields should be from: %s",
+			strings.Join(unknown, ", "),
+			strings.Join(TransactionCsvHeader, ", "),
+		)
+	}
+
+	return projection, nil
+}
+
+// Header returns the CSV column names of the projection.
+func (p TransactionProjection) Header() []string {
+	if p == nil {
+		return TransactionCsvHeader
+	}
+
+	return p.project(TransactionCsvHeader)
+}
+
+// FieldNames returns names of the Transaction fields of the projection, nil is
+// returned for the nil projection. The names are accepted by gorm's Select.
+func (p TransactionProjection) FieldNames() []string {
+	if p == nil {
+		return nil
+	}
+
+	return p.project(transactionStructFields)
+}
+
+// Apply returns the transactions, which are marshalled to JSON with the fields of
+// the projection only. The transactions are returned as is for the nil projection.
+func (p TransactionProjection) Apply(transactions []Transaction) interface{} {
+	if p == nil {
+		return transactions
+	}
+
+	projected := make([]ProjectedTransaction, len(transactions))
+	for i := range transactions {
+		projected[i] = ProjectedTransaction{Transaction: &transactions[i], Projection: p}
+	}
+
+	return projected
+}
+
+// project returns the values of the columns of the projection from
+// the values given in the order of the columns in transactionColumns.
+func (p TransactionProjection) project(values []string) []string {
+	if p == nil {
+		return values
+	}
+
+	projected := make([]string, len(p))
+	for i, index := range p {
+		projected[i] = values[index]
+	}
+
+	return projected
+}
+
+// ProjectedTransaction is marshalled to JSON with the fields of the projection
+// in the order of the projection.
+type ProjectedTransaction struct {
+	Transaction *Transaction
+	Projection  TransactionProjection
+}
+
+// MarshalJSON writes the fields of the projection only, the values are marshalled with json.Marshal,
+// so they are the same as in the transactions marshalled as a whole. The fields which are written
+// to the output only, such as the bank name, are written after them unless they are empty.
+// All fields are written by json.Marshal for the nil projection.
+func (t ProjectedTransaction) MarshalJSON() ([]byte, error) {
+	if t.Projection == nil {
+		return json.Marshal(t.Transaction)
+	}
+
+	var err error
+	value := reflect.ValueOf(t.Transaction).Elem()
+	data := append(make([]byte, 0, 32*len(t.Projection)), '{')
+	for _, index := range t.Projection {
+		data, err = transactionProjectedFields[index].append(data, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range transactionOutputFields {
+		if field.omitEmpty && value.Field(field.index).IsZero() {
+			continue
+		}
+
+		data, err = field.append(data, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return append(data, '}'), nil
+}
+
+// projectedField is a Transaction field written by ProjectedTransaction.
+type projectedField struct {
+	// index is the index of the field in Transaction
+	index int
+
+	name      string
+	omitEmpty bool
+}
+
+var (
+	// transactionProjectedFields contains the fields of the columns
+	// in the order of the columns in transactionColumns
+	transactionProjectedFields = newTransactionProjectedFields()
+
+	// transactionOutputFields contains the fields from jsonOutputFields in the order of Transaction
+	transactionOutputFields = newTransactionOutputFields()
+)
+
+func newTransactionProjectedFields() []projectedField {
+	fields := make([]projectedField, len(transactionColumns))
+	for _, field := range newProjectedFields() {
+		index, ok := transactionColumnIndexes[NormalizeCsvColumnName(field.name)]
+		if ok {
+			fields[index] = field
+		}
+	}
+
+	return fields
+}
+
+func newTransactionOutputFields() []projectedField {
+	var fields []projectedField
+	for _, field := range newProjectedFields() {
+		if jsonOutputFields[field.name] {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// newProjectedFields returns the Transaction fields which are marshalled to JSON by their tags.
+func newProjectedFields() []projectedField {
+	var fields []projectedField
+	transactionType := reflect.TypeOf(Transaction{})
+	for i := 0; i < transactionType.NumField(); i++ {
+		tag := strings.Split(transactionType.Field(i).Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+
+		field := projectedField{index: i, name: tag[0]}
+		for _, option := range tag[1:] {
+			field.omitEmpty = field.omitEmpty || option == "omitempty"
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// append appends the field of the transaction to the object started in data,
+// it is separated by a comma unless it is the first field.
+func (f projectedField) append(data []byte, transaction reflect.Value) ([]byte, error) {
+	name, err := json.Marshal(f.name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(transaction.Field(f.index).Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > 1 {
+		data = append(data, ',')
+	}
+
+	data = append(data, name...)
+	data = append(data, ':')
+	return append(data, value...), nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTransactionProjection(t *testing.T) {
+	projection, err := ParseTransactionProjection(" DatePost, payee_bank_account,TransactionId ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedHeader := []string{"DatePost", "PayeeBankAccount", "TransactionId"}
+	if !reflect.DeepEqual(projection.Header(), expectedHeader) {
+		t.Errorf("%v != %v", expectedHeader, projection.Header())
+	}
+
+	expectedFields := []string{"DatePost", "PayeeBankAccount", "Id"}
+	if !reflect.DeepEqual(projection.FieldNames(), expectedFields) {
+		t.Errorf("%v != %v", expectedFields, projection.FieldNames())
+	}
+
+	projection, err = ParseTransactionProjection("")
+	if err != nil || projection != nil {
+		t.Errorf("expected the nil projection, received %v, %v", projection, err)
+	}
+
+	if projection.FieldNames() != nil || !reflect.DeepEqual(projection.Header(), TransactionCsvHeader) {
+		t.Errorf("expected all fields to be selected by the nil projection")
+	}
+
+	for _, value := range []string{"Status,Unknown", "Status,status", "Status,", "payee_bank_name"} {
+		_, err = ParseTransactionProjection(value)
+		if err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestTransactionProjection_FieldNames_AllColumns(t *testing.T) {
+	projection, err := ParseTransactionProjection(strings.Join(TransactionCsvHeader, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionType := reflect.TypeOf(Transaction{})
+	for _, name := range projection.FieldNames() {
+		_, ok := transactionType.FieldByName(name)
+		if !ok {
+			t.Errorf("expected a field of Transaction, received %q", name)
+		}
+	}
+}
+
+func TestProjectedTransaction_MarshalJSON(t *testing.T) {
+	transaction, err := NewTransactionCsvDialectReader(strings.NewReader(excelUaTestRow), CsvDialectPresets["excel-ua"]).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	projection, err := ParseTransactionProjection("Status,AmountTotal,DateInput")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(projection.Apply([]Transaction{transaction}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"status":"accepted","amount_total":1,"date_input":"2022-08-12T11:25:27Z"}]`
+	if string(data) != expected {
+		t.Errorf("%v != %v", expected, string(data))
+	}
+}
+
+func TestProjectedTransaction_MarshalJSON_AllColumns(t *testing.T) {
+	transaction := Transaction{
+		Id:                 math.MaxUint64,
+		RequestId:          20020,
+		TerminalId:         3506,
+		PartnerObjectId:    1111,
+		AmountTotal:        1e-7,
+		AmountOriginal:     1e21,
+		CommissionPS:       16777216.5,
+		CommissionClient:   -0.01,
+		CommissionProvider: 0,
+		DateInput:          time.Date(2022, 8, 12, 11, 25, 27, 0, time.UTC),
+		DatePost:           time.Date(2022, 8, 12, 14, 25, 27, 500, time.FixedZone("EEST", 3*60*60)),
+		Status:             ACCEPTED,
+		PaymentType:        CARD,
+		PaymentNumber:      "PS16698205",
+		ServiceId:          13980,
+		Service:            "Поповнення карток",
+		PayeeId:            14232155,
+		PayeeName:          "A & B <c>",
+		PayeeBankMfo:       254751,
+		PayeeBankAccount:   "UA713451373919523",
+		PaymentNarrative:   "\"quoted\"\n ",
+		PayeeBankName:      "ПУМБ",
+	}
+	importId := uint64(math.MaxUint64)
+	transaction.ImportId = &importId
+
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected map[string]json.RawMessage
+	err = json.Unmarshal(data, &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range transactionJsonFields {
+		t.Run(field, func(t *testing.T) {
+			projection, err := ParseTransactionProjection(field)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(ProjectedTransaction{Transaction: &transaction, Projection: projection})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the fields written to the output only are kept
+			expectedData := `{"` + field + `":` + string(expected[field]) +
+				`,"payee_bank_name":` + string(expected["payee_bank_name"]) +
+				`,"import_id":` + string(expected["import_id"]) + `}`
+			if string(data) != expectedData {
+				t.Errorf("%v != %v", expectedData, string(data))
+			}
+		})
+	}
+}
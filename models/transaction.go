@@ -2,8 +2,12 @@ package models
 
 import (
 	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,8 +15,25 @@ const (
 	numberOfTransactionFields = 21
 
 	TimeLayout = "2006-01-02 15:04:05"
+
+	minPayeeBankMfo = 100000
+	maxPayeeBankMfo = 999999
+
+	// ukrainianIbanLength is the length of "UA", the check digits,
+	// the bank MFO of 6 digits and the account number of 19 characters
+	ukrainianIbanLength = 29
 )
 
+var (
+	paymentNumberRegexp    = regexp.MustCompile(`^[A-Z]{2}[0-9]{8}$`)
+	payeeBankAccountRegexp = regexp.MustCompile(`^[A-Z]{2}[0-9]{15}$`)
+	ukrainianIbanRegexp    = regexp.MustCompile(`^UA[0-9]{27}$`)
+)
+
+// TransactionCsvHeader contains names of the transaction columns in the
+// order they are exported to and expected in CSV files by default.
+var TransactionCsvHeader = transactionColumnNames()
+
 type StatusType string
 
 const (
@@ -65,152 +86,399 @@ type Transaction struct {
 	PayeeId            uint64          `json:"payee_id"`
 	PayeeName          string          `json:"payee_name"`
 	PayeeBankMfo       uint32          `json:"payee_bank_mfo"`
-	PayeeBankAccount   string          `gorm:"size:17;check:payee_bank_account ~ '[A-Z]{2}[0-9]{15}'" json:"payee_bank_account"`
+	PayeeBankAccount   string          `gorm:"size:34;check:chk_transactions_payee_bank_account_format,payee_bank_account ~ '^([A-Z]{2}[0-9]{15}|UA[0-9]{27})$'" json:"payee_bank_account"`
 	PaymentNarrative   string          `json:"payment_narrative"`
+
+	// PayeeBankName is set from BankDirectory for the JSON output, it is not stored.
+	PayeeBankName string `gorm:"-" json:"payee_bank_name,omitempty"`
+
+	// ImportId refers to the import which created or last updated the transaction.
+	ImportId *uint64 `gorm:"index" json:"import_id,omitempty"`
 }
 
-func (t *Transaction) ToCsvRow() string {
-	return fmt.Sprintf(
-		"%d,%d,%d,%d,%.2f,%.2f,%.2f,%.2f,%.2f,%s,%s,%s,%s,%s,%d,%s,%d,%s,%d,%s,%s",
-		t.Id,
-		t.RequestId,
-		t.TerminalId,
-		t.PartnerObjectId,
-		t.AmountTotal,
-		t.AmountOriginal,
-		t.CommissionPS,
-		t.CommissionClient,
-		t.CommissionProvider,
-		t.DateInput.Format(TimeLayout),
-		t.DatePost.Format(TimeLayout),
-		t.Status,
-		t.PaymentType,
+// ToCsvRecord returns transaction fields in the order of TransactionCsvHeader.
+func (t *Transaction) ToCsvRecord() []string {
+	return t.toCsvRecord(&DefaultCsvDialect)
+}
+
+func (t *Transaction) toCsvRecord(dialect *CsvDialect) []string {
+	return []string{
+		strconv.FormatUint(t.Id, 10),
+		strconv.FormatUint(t.RequestId, 10),
+		strconv.FormatUint(t.TerminalId, 10),
+		strconv.FormatUint(uint64(t.PartnerObjectId), 10),
+		dialect.formatFloat32(t.AmountTotal),
+		dialect.formatFloat32(t.AmountOriginal),
+		dialect.formatFloat32(t.CommissionPS),
+		dialect.formatFloat32(t.CommissionClient),
+		dialect.formatFloat32(t.CommissionProvider),
+		dialect.formatTime(t.DateInput),
+		dialect.formatTime(t.DatePost),
+		string(t.Status),
+		string(t.PaymentType),
 		t.PaymentNumber,
-		t.ServiceId,
+		strconv.FormatUint(t.ServiceId, 10),
 		t.Service,
-		t.PayeeId,
+		strconv.FormatUint(t.PayeeId, 10),
 		t.PayeeName,
-		t.PayeeBankMfo,
+		strconv.FormatUint(uint64(t.PayeeBankMfo), 10),
 		t.PayeeBankAccount,
 		t.PaymentNarrative,
-	)
+	}
 }
 
-func NewTransactionFromCSVRow(fields []string) (Transaction, error) {
-	fieldsLen := len(fields)
-	if fieldsLen != numberOfTransactionFields {
-		return Transaction{}, fmt.Errorf(
-			"invalid number of transaction fields: %d required, %d got",
-			numberOfTransactionFields,
-			fieldsLen,
-		)
+// FieldError describes a single field of the row which cannot be
+// converted to the corresponding transaction attribute.
+type FieldError struct {
+	Index int
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %d: %v", e.Index+1, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is returned by NewTransactionFromCSVRow when one or more fields are invalid.
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
 	}
 
-	var err error
-	transaction := Transaction{}
-	transaction.Id, err = parseUint64(fields[0])
-	if err != nil {
-		return Transaction{}, err
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the business rules which are not enforced by the types of the fields.
+// FieldErrors with indexes of the columns in TransactionCsvHeader is returned, so that
+// the problems are reported for each row instead of failing database constraints.
+func (t *Transaction) Validate() error {
+	var fieldErrors FieldErrors
+	record := t.ToCsvRecord()
+	addError := func(column string, format string, args ...interface{}) {
+		index := transactionColumnIndexes[NormalizeCsvColumnName(column)]
+		fieldErrors = append(fieldErrors, &FieldError{Index: index, Value: record[index], Err: fmt.Errorf(format, args...)})
 	}
 
-	transaction.RequestId, err = parseUint64(fields[1])
-	if err != nil {
-		return Transaction{}, err
+	if t.AmountTotal < 0 {
+		addError("AmountTotal", "the amount should not be negative")
 	}
 
-	transaction.TerminalId, err = parseUint64(fields[2])
-	if err != nil {
-		return Transaction{}, err
+	if t.AmountOriginal < 0 {
+		addError("AmountOriginal", "the amount should not be negative")
 	}
 
-	partnerObjectId, err := strconv.ParseUint(fields[3], 10, 16)
-	if err != nil {
-		return Transaction{}, err
+	// the client pays the commission in addition to the original amount
+	if !amountsAreEqual(t.AmountTotal, t.AmountOriginal, t.CommissionClient) {
+		addError(
+			"AmountTotal",
+			"the total amount should be equal to the original amount %s plus the client commission %s",
+			formatFloat32(t.AmountOriginal),
+			formatFloat32(t.CommissionClient),
+		)
 	}
 
-	transaction.PartnerObjectId = uint16(partnerObjectId)
-	transaction.AmountTotal, err = parseFloat32(fields[4])
-	if err != nil {
-		return Transaction{}, err
+	if t.DatePost.Before(t.DateInput) {
+		addError("DatePost", "the post date should not be before the input date %s", t.DateInput.Format(TimeLayout))
 	}
 
-	transaction.AmountOriginal, err = parseFloat32(fields[5])
-	if err != nil {
-		return Transaction{}, err
+	if !paymentNumberRegexp.MatchString(t.PaymentNumber) {
+		addError("PaymentNumber", "the payment number should be two capital letters followed by 8 digits")
 	}
 
-	transaction.CommissionPS, err = parseFloat32(fields[6])
-	if err != nil {
-		return Transaction{}, err
+	if t.PayeeBankMfo < minPayeeBankMfo || t.PayeeBankMfo > maxPayeeBankMfo {
+		addError("PayeeBankMfo", "the bank MFO should be a number of 6 digits")
 	}
 
-	transaction.CommissionClient, err = parseFloat32(fields[7])
+	err := validatePayeeBankAccount(t.PayeeBankAccount, t.PayeeBankMfo)
 	if err != nil {
-		return Transaction{}, err
+		addError("PayeeBankAccount", "%v", err)
 	}
 
-	transaction.CommissionProvider, err = parseFloat32(fields[8])
-	if err != nil {
-		return Transaction{}, err
+	if len(fieldErrors) > 0 {
+		return fieldErrors
 	}
 
-	transaction.DateInput, err = time.Parse(TimeLayout, fields[9])
-	if err != nil {
-		return Transaction{}, err
+	return nil
+}
+
+// validatePayeeBankAccount accepts either a Ukrainian IBAN of the bank with
+// the MFO, or an account number in the legacy format.
+func validatePayeeBankAccount(account string, mfo uint32) error {
+	if !strings.HasPrefix(account, "UA") || len(account) != ukrainianIbanLength {
+		if !payeeBankAccountRegexp.MatchString(account) {
+			return fmt.Errorf(
+				"the bank account should be a Ukrainian IBAN of %d characters or two capital letters followed by 15 digits",
+				ukrainianIbanLength,
+			)
+		}
+
+		return nil
 	}
 
-	transaction.DatePost, err = time.Parse(TimeLayout, fields[10])
-	if err != nil {
-		return Transaction{}, err
+	if !ukrainianIbanRegexp.MatchString(account) {
+		return errors.New("the IBAN should contain the check digits, the bank MFO and the account number of 19 digits")
 	}
 
-	switch status := StatusType(fields[11]); status {
-	case ACCEPTED, DECLINED:
-		transaction.Status = status
-	default:
-		return Transaction{}, fmt.Errorf("invalid status, expected %s or %s, received %v", ACCEPTED, DECLINED, status)
+	if !isValidIbanChecksum(account) {
+		return errors.New("invalid check digits of the IBAN")
 	}
 
-	switch paymentType := PaymentTypeType(fields[12]); paymentType {
-	case CASH, CARD:
-		transaction.PaymentType = paymentType
-	default:
-		return Transaction{}, fmt.Errorf(
-			"invalid payment type, expected %s or %s, received %v",
-			CASH,
-			CARD,
-			paymentType,
-		)
+	ibanMfo := account[4:10]
+	if ibanMfo != fmt.Sprintf("%06d", mfo) {
+		return fmt.Errorf("the IBAN belongs to the bank with MFO %s instead of %d", ibanMfo, mfo)
 	}
 
-	transaction.PaymentNumber = fields[13]
-	transaction.ServiceId, err = parseUint64(fields[14])
-	if err != nil {
-		return Transaction{}, err
+	return nil
+}
+
+// isValidIbanChecksum checks the IBAN with the mod-97 algorithm of ISO 13616:
+// the country code and the check digits are moved to the end, letters are
+// replaced with numbers from 10 to 35, and the number should give 1 modulo 97.
+func isValidIbanChecksum(iban string) bool {
+	remainder := 0
+	for _, character := range iban[4:] + iban[:4] {
+		switch {
+		case character >= '0' && character <= '9':
+			remainder = (remainder*10 + int(character-'0')) % 97
+		case character >= 'A' && character <= 'Z':
+			remainder = (remainder*100 + int(character-'A') + 10) % 97
+		default:
+			return false
+		}
 	}
 
-	transaction.Service = fields[15]
-	transaction.PayeeId, err = parseUint64(fields[16])
-	if err != nil {
-		return Transaction{}, err
+	return remainder == 1
+}
+
+// NewTransactionFromCSVRow parses fields that are ordered as TransactionCsvHeader.
+func NewTransactionFromCSVRow(fields []string) (Transaction, error) {
+	fieldsLen := len(fields)
+	if fieldsLen != numberOfTransactionFields {
+		return Transaction{}, fmt.Errorf(
+			"invalid number of transaction fields: %d required, %d got",
+			numberOfTransactionFields,
+			fieldsLen,
+		)
 	}
 
-	transaction.PayeeName = fields[17]
-	payeeBandMfo, err := strconv.ParseInt(fields[18], 10, 32)
-	if err != nil {
-		return Transaction{}, err
+	return defaultCsvHeaderMapping.newTransaction(fields, &DefaultCsvDialect)
+}
+
+type transactionColumn struct {
+	name  string
+	parse func(t *Transaction, value string, dialect *CsvDialect) error
+}
+
+var transactionColumns = []transactionColumn{
+	{
+		name: "TransactionId",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.Id, err = parseUint64(value)
+			return err
+		},
+	},
+	{
+		name: "RequestId",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.RequestId, err = parseUint64(value)
+			return err
+		},
+	},
+	{
+		name: "TerminalId",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.TerminalId, err = parseUint64(value)
+			return err
+		},
+	},
+	{
+		name: "PartnerObjectId",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			partnerObjectId, err := strconv.ParseUint(value, 10, 16)
+			t.PartnerObjectId = uint16(partnerObjectId)
+			return err
+		},
+	},
+	{
+		name: "AmountTotal",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.AmountTotal, err = dialect.parseFloat32(value)
+			return err
+		},
+	},
+	{
+		name: "AmountOriginal",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.AmountOriginal, err = dialect.parseFloat32(value)
+			return err
+		},
+	},
+	{
+		name: "CommissionPS",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.CommissionPS, err = dialect.parseFloat32(value)
+			return err
+		},
+	},
+	{
+		name: "CommissionClient",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.CommissionClient, err = dialect.parseFloat32(value)
+			return err
+		},
+	},
+	{
+		name: "CommissionProvider",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.CommissionProvider, err = dialect.parseFloat32(value)
+			return err
+		},
+	},
+	{
+		name: "DateInput",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.DateInput, err = dialect.parseTime(value)
+			return err
+		},
+	},
+	{
+		name: "DatePost",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.DatePost, err = dialect.parseTime(value)
+			return err
+		},
+	},
+	{
+		name: "Status",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			switch status := StatusType(value); status {
+			case ACCEPTED, DECLINED:
+				t.Status = status
+				return nil
+			default:
+				return fmt.Errorf("invalid status, expected %s or %s, received %v", ACCEPTED, DECLINED, status)
+			}
+		},
+	},
+	{
+		name: "PaymentType",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			switch paymentType := PaymentTypeType(value); paymentType {
+			case CASH, CARD:
+				t.PaymentType = paymentType
+				return nil
+			default:
+				return fmt.Errorf(
+					"invalid payment type, expected %s or %s, received %v",
+					CASH,
+					CARD,
+					paymentType,
+				)
+			}
+		},
+	},
+	{
+		name: "PaymentNumber",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			t.PaymentNumber = value
+			return nil
+		},
+	},
+	{
+		name: "ServiceId",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.ServiceId, err = parseUint64(value)
+			return err
+		},
+	},
+	{
+		name: "Service",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			t.Service = value
+			return nil
+		},
+	},
+	{
+		name: "PayeeId",
+		parse: func(t *Transaction, value string, dialect *CsvDialect) (err error) {
+			t.PayeeId, err = parseUint64(value)
+			return err
+		},
+	},
+	{
+		name: "PayeeName",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			t.PayeeName = value
+			return nil
+		},
+	},
+	{
+		name: "PayeeBankMfo",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			payeeBankMfo, err := strconv.ParseInt(value, 10, 32)
+			t.PayeeBankMfo = uint32(payeeBankMfo)
+			return err
+		},
+	},
+	{
+		name: "PayeeBankAccount",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			t.PayeeBankAccount = value
+			return nil
+		},
+	},
+	{
+		name: "PaymentNarrative",
+		parse: func(t *Transaction, value string, _ *CsvDialect) error {
+			t.PaymentNarrative = value
+			return nil
+		},
+	},
+}
+
+func transactionColumnNames() []string {
+	names := make([]string, len(transactionColumns))
+	for i, column := range transactionColumns {
+		names[i] = column.name
 	}
 
-	transaction.PayeeBankMfo = uint32(payeeBandMfo)
-	transaction.PayeeBankAccount = fields[19]
-	transaction.PaymentNarrative = fields[20]
-	return transaction, nil
+	return names
 }
 
 func parseUint64(s string) (uint64, error) {
 	return strconv.ParseUint(s, 10, 64)
 }
 
+// amountsAreEqual checks that total is the sum of the amounts in whole cents. A float32
+// keeps about 7 significant digits, so amounts from 167772.16 do not fit whole cents.
+// The difference within the float32 precision of the largest amount is allowed for them.
+func amountsAreEqual(total float32, amounts ...float32) bool {
+	largest := math.Abs(float64(total))
+	sum := 0.0
+	for _, amount := range amounts {
+		largest = math.Max(largest, math.Abs(float64(amount)))
+		sum += toCents(amount)
+	}
+
+	precision := float64(math.Nextafter32(float32(largest), math.MaxFloat32)-float32(largest)) * 100
+	return math.Abs(toCents(total)-sum) <= precision
+}
+
+func toCents(amount float32) float64 {
+	return math.Round(float64(amount) * 100)
+}
+
+func formatFloat32(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', 2, 32)
+}
+
 func parseFloat32(s string) (float32, error) {
 	v, err := strconv.ParseFloat(s, 32)
 	if err != nil {
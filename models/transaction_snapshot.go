@@ -0,0 +1,11 @@
+package models
+
+// TransactionSnapshot keeps a transaction as it was before an import updated it,
+// so that the transaction is restored when the import is reverted or deleted.
+// Transactions created by the import have no snapshots, they are deleted instead.
+type TransactionSnapshot struct {
+	// UpdatedByImportId refers to the import which updated the transaction.
+	UpdatedByImportId uint64 `gorm:"primaryKey;autoIncrement:false"`
+
+	Transaction `gorm:"embedded"`
+}
@@ -8,7 +8,7 @@ import (
 	"time"
 )
 
-func TestTransaction_ToCsvRow(t *testing.T) {
+func TestTransaction_ToCsvRecord(t *testing.T) {
 	transaction := Transaction{
 		Id:                 1,
 		RequestId:          20020,
@@ -33,7 +33,7 @@ func TestTransaction_ToCsvRow(t *testing.T) {
 		PaymentNarrative:   "Перерахування коштів згідно договору про надання послуг А11/27122 від 19.11.2020 р.",
 	}
 	expected := "1,20020,3506,1111,1.00,1.00,0.00,0.00,0.00,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів згідно договору про надання послуг А11/27122 від 19.11.2020 р."
-	actual := transaction.ToCsvRow()
+	actual := strings.Join(transaction.ToCsvRecord(), ",")
 	if expected != actual {
 		t.Errorf("\n%v\n!=\n%v", expected, actual)
 	}
@@ -325,6 +325,90 @@ func Test_parseFloat32_OverflowError(t *testing.T) {
 	}
 }
 
+func TestTransaction_Validate(t *testing.T) {
+	csvData := "1,20020,3506,1111,3.50,3.00,0.00,0.50,-0.01,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,PS16698205,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів"
+	_, err := NewTransactionFromCSVRow(strings.Split(csvData, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		replace  map[int]string
+		expected []int
+	}{
+		{"NegativeAmounts", map[int]string{4: "-1.00", 5: "-1.50"}, []int{4, 5}},
+		{"InconsistentAmountTotal", map[int]string{4: "3.00"}, []int{4}},
+		{"InconsistentAmountTotalByCent", map[int]string{4: "3.51"}, []int{4}},
+		{"LargeAmounts", map[int]string{4: "1000000.07", 5: "1000000.00", 7: "0.07"}, nil},
+		{"LargeAmountsWithCommission", map[int]string{4: "12345678.90", 5: "12345000.00", 7: "678.90"}, nil},
+		{"InconsistentLargeAmountTotal", map[int]string{4: "1000001.07", 5: "1000000.00", 7: "0.07"}, []int{4}},
+		{"DatePostBeforeDateInput", map[int]string{10: "2022-08-12 11:25:26"}, []int{10}},
+		{"InvalidPaymentNumber", map[int]string{13: "ps16698205"}, []int{13}},
+		{"ShortPaymentNumber", map[int]string{13: "PS1669820"}, []int{13}},
+		{"ShortPayeeBankMfo", map[int]string{18: "25475"}, []int{18}},
+		{"LongPayeeBankMfo", map[int]string{18: "2547510"}, []int{18}},
+		{"InvalidPayeeBankAccount", map[int]string{19: "UA71345137391952"}, []int{19}},
+		{"Iban", map[int]string{19: "UA272547510000026001234567890"}, nil},
+		{"IbanOfAnotherBank", map[int]string{18: "305299", 19: "UA272547510000026001234567890"}, []int{19}},
+		{"IbanWithInvalidCheckDigits", map[int]string{19: "UA282547510000026001234567890"}, []int{19}},
+		{"IbanWithSwappedDigits", map[int]string{19: "UA272547510000026001234567809"}, []int{19}},
+		{"IbanWithLowerCaseLetters", map[int]string{19: "UA27254751000002600123456789a"}, []int{19}},
+		{"IbanWithCapitalLetters", map[int]string{19: "UA72254751000002600123456789A"}, []int{19}},
+	}
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				fields := strings.Split(csvData, ",")
+				for index, value := range test.replace {
+					fields[index] = value
+				}
+
+				_, err := NewTransactionFromCSVRow(fields)
+				if test.expected == nil {
+					if err != nil {
+						t.Errorf("unexpected error: %v", err)
+					}
+
+					return
+				}
+
+				fieldErrors, ok := err.(FieldErrors)
+				if !ok || len(fieldErrors) != len(test.expected) {
+					t.Fatalf("expected errors of fields %v, received %v", test.expected, err)
+				}
+
+				for i, fieldErr := range fieldErrors {
+					if fieldErr.Index != test.expected[i] || fieldErr.Value != fields[test.expected[i]] {
+						t.Errorf("expected an error of field %d, received %+v", test.expected[i], fieldErr)
+					}
+				}
+			},
+		)
+	}
+}
+
+func TestTransactionCsvReader_Read_ValidationErrorColumn(t *testing.T) {
+	header := append([]string{"PaymentNumber"}, TransactionCsvHeader[:13]...)
+	header = append(header, TransactionCsvHeader[14:]...)
+	fields := strings.Split(
+		"1,20020,3506,1111,1.00,1.00,0.00,0.00,-0.01,2022-08-12 11:25:27,2022-08-12 14:25:27,accepted,cash,13980,Поповнення карток,14232155,pumb,254751,UA713451373919523,Перерахування коштів",
+		",",
+	)
+	csvData := strings.Join(header, ",") + "\nP16698205," + strings.Join(fields, ",") + "\n"
+	reader := NewTransactionCsvReader(strings.NewReader(csvData))
+	err := reader.ReadHeader(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.Read()
+	rowErrors, ok := err.(RowErrors)
+	if !ok || len(rowErrors) != 1 || rowErrors[0].Column != "PaymentNumber" || rowErrors[0].Value != "P16698205" {
+		t.Errorf("expected an error of PaymentNumber, received %v", err)
+	}
+}
+
 func Test_parseUint64_PositiveIntParsed(t *testing.T) {
 	expected := uint64(math.MaxUint64)
 	actual, err := parseUint64(fmt.Sprintf("%d", expected))
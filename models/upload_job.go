@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type UploadJobState string
+
+const (
+	UploadJobQueued    UploadJobState = "queued"
+	UploadJobRunning   UploadJobState = "running"
+	UploadJobCompleted UploadJobState = "completed"
+	UploadJobFailed    UploadJobState = "failed"
+
+	// UploadJobInterrupted is set for jobs which were running when the
+	// server stopped unexpectedly, so it is unknown if they were saved.
+	UploadJobInterrupted UploadJobState = "interrupted"
+)
+
+// UploadJob is a file uploaded in the asynchronous mode together with
+// the upload options and the progress of its processing. ContentType is
+// the type the file was uploaded with, it is used to detect the format of the file.
+type UploadJob struct {
+	Id                   string         `gorm:"primaryKey;size:32" json:"id"`
+	State                UploadJobState `gorm:"size:16;not null;index" json:"state"`
+	FileName             string         `json:"file_name"`
+	ContentType          string         `json:"-"`
+	Uploader             string         `json:"uploader"`
+	RejectUnknownColumns bool           `json:"-"`
+	Partial              bool           `json:"-"`
+	OnConflict           string         `gorm:"size:16" json:"-"`
+	Force                bool           `json:"-"`
+	Encoding             string         `gorm:"size:32" json:"-"`
+	CsvDialect           CsvDialect     `gorm:"type:text;serializer:json" json:"-"`
+	RowsProcessed        int            `json:"rows_processed"`
+	RowCount             int            `json:"row_count"`
+	InsertedCount        int            `json:"inserted_count"`
+	SkippedCount         int            `json:"skipped_count"`
+	UpdatedCount         int            `json:"updated_count"`
+	RejectedCount        int            `json:"rejected_count"`
+	ImportId             *uint64        `json:"import_id"`
+	Files                []ImportedFile `gorm:"type:text;serializer:json" json:"files,omitempty"`
+	RejectedRowsId       string         `gorm:"size:32" json:"-"`
+	RejectedRowsUrl      string         `gorm:"-" json:"rejected_rows_url,omitempty"`
+	Errors               []RowError     `gorm:"type:text;serializer:json" json:"errors,omitempty"`
+	ErrorsTruncated      bool           `json:"errors_truncated,omitempty"`
+	Message              string         `json:"message,omitempty"`
+	CreatedAt            time.Time      `json:"created_at"`
+	StartedAt            *time.Time     `json:"started_at"`
+	FinishedAt           *time.Time     `json:"finished_at"`
+}
@@ -0,0 +1,436 @@
+package models
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	xlsxSheetName = "Transactions"
+	xlsxHeader    = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+	xlsxMainNs    = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxRelsNs    = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	// xlsxMaxExactInteger is the greatest integer shown by Excel without rounding,
+	// as Excel keeps 15 significant digits. Greater ids are written as text.
+	xlsxMaxExactInteger = 999999999999999
+
+	// xlsxMaxRows is the limit of rows in a sheet including the header,
+	// Excel does not open the workbook if a sheet has more rows
+	xlsxMaxRows = 1048576
+)
+
+// Indexes of the cell formats in the cellXfs of xl/styles.xml.
+const (
+	xlsxStyleGeneral = iota
+	xlsxStyleInteger
+	xlsxStyleAmount
+	xlsxStyleDate
+	xlsxStyleHeader
+	xlsxStyleText
+)
+
+// xlsxStaticParts do not depend on the number of sheets, the other parts are written by Close.
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{
+		name: "_rels/.rels",
+		content: xlsxHeader +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + xlsxRelsNs + `/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		name: "xl/styles.xml",
+		content: xlsxHeader +
+			`<styleSheet xmlns="` + xlsxMainNs + `">` +
+			`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+			`<fonts count="2">` +
+			`<font><sz val="11"/><name val="Calibri"/></font>` +
+			`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+			`</fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="6">` +
+			`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="1" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+			`<xf numFmtId="49" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`</cellXfs>` +
+			`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+			`</styleSheet>`,
+	},
+}
+
+// xlsxColumn describes how the values of a transaction column are written to cells.
+type xlsxColumn struct {
+	style int
+	width int
+
+	// time returns the value of a date column, which is written as a number of days
+	time func(t *Transaction) time.Time
+}
+
+var (
+	xlsxIntegerColumn = xlsxColumn{style: xlsxStyleInteger, width: 12}
+	xlsxAmountColumn  = xlsxColumn{style: xlsxStyleAmount, width: 12}
+	xlsxTextColumn    = xlsxColumn{style: xlsxStyleText, width: 16}
+
+	// transactionXlsxColumns contains the columns in the order of transactionColumns
+	transactionXlsxColumns = []xlsxColumn{
+		xlsxIntegerColumn,
+		xlsxIntegerColumn,
+		xlsxIntegerColumn,
+		xlsxIntegerColumn,
+		xlsxAmountColumn,
+		xlsxAmountColumn,
+		xlsxAmountColumn,
+		xlsxAmountColumn,
+		xlsxAmountColumn,
+		{style: xlsxStyleDate, width: 20, time: func(t *Transaction) time.Time { return t.DateInput }},
+		{style: xlsxStyleDate, width: 20, time: func(t *Transaction) time.Time { return t.DatePost }},
+		xlsxTextColumn,
+		xlsxTextColumn,
+		xlsxTextColumn,
+		xlsxIntegerColumn,
+		{style: xlsxStyleText, width: 24},
+		xlsxIntegerColumn,
+		{style: xlsxStyleText, width: 24},
+		xlsxIntegerColumn,
+		{style: xlsxStyleText, width: 32},
+		{style: xlsxStyleText, width: 60},
+	}
+)
+
+// TransactionXlsxWriter writes transactions to an Excel workbook. Each sheet has
+// a frozen header row and an autofilter, the rows which exceed the limit of Excel
+// are written to the next sheet. Numbers and dates are written as typed cells, the
+// other values are written as text. The rows are compressed and written to the
+// underlying io.Writer as they come, so the workbook is not kept in memory.
+//
+// The workbook is written here rather than by a library, because excelize, the
+// maintained one, keeps the rows of its StreamWriter in a temporary file until
+// the workbook is saved, so the export could not be sent while it is read.
+// The package has only the parts required by ECMA-376 for a workbook with inline
+// strings. The tests check that the parts are well-formed XML and that the content
+// types and the relationships refer to the written parts, the output has not been
+// checked in particular versions of Excel or LibreOffice.
+type TransactionXlsxWriter struct {
+	archive    *zip.Writer
+	sheet      *bufio.Writer
+	projection TransactionProjection
+
+	// columns contains indexes of the written columns in transactionColumns
+	columns []int
+
+	// maxRows is the limit of rows in a sheet including the header
+	maxRows int
+
+	// rowCount is the number of rows written to the current sheet including the header
+	rowCount int
+
+	// sheetRowCounts contains the numbers of rows of the completed sheets
+	sheetRowCounts []int
+}
+
+func NewTransactionXlsxWriter(w io.Writer) *TransactionXlsxWriter {
+	return &TransactionXlsxWriter{archive: zip.NewWriter(w), maxRows: xlsxMaxRows}
+}
+
+// SetProjection selects the columns to be written in the order of the
+// projection. It should be called before WriteHeader.
+func (w *TransactionXlsxWriter) SetProjection(projection TransactionProjection) {
+	w.projection = projection
+}
+
+// WriteHeader writes the parts of the workbook which precede the rows,
+// and the header row of the first sheet. It should be called before Write.
+func (w *TransactionXlsxWriter) WriteHeader() error {
+	for _, part := range xlsxStaticParts {
+		err := w.writePart(part.name, part.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.columns = w.projection
+	if w.columns == nil {
+		w.columns = make([]int, len(transactionColumns))
+		for i := range w.columns {
+			w.columns[i] = i
+		}
+	}
+
+	return w.startSheet()
+}
+
+// startSheet starts the next sheet with the header row.
+func (w *TransactionXlsxWriter) startSheet() error {
+	name := fmt.Sprintf("xl/worksheets/sheet%d.xml", len(w.sheetRowCounts)+1)
+	sheet, err := w.archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	w.sheet = bufio.NewWriter(sheet)
+	w.rowCount = 0
+	cols := strings.Builder{}
+	for i, index := range w.columns {
+		fmt.Fprintf(
+			&cols, `<col min="%d" max="%d" width="%d" customWidth="1"/>`,
+			i+1, i+1, transactionXlsxColumns[index].width,
+		)
+	}
+
+	_, err = w.sheet.WriteString(
+		xlsxHeader + `<worksheet xmlns="` + xlsxMainNs + `" xmlns:r="` + xlsxRelsNs + `">` +
+			`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`<selection pane="bottomLeft" activeCell="A2" sqref="A2"/>` +
+			`</sheetView></sheetViews>` +
+			`<cols>` + cols.String() + `</cols><sheetData>`,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = w.startRow()
+	if err != nil {
+		return err
+	}
+
+	for i, name := range w.projection.Header() {
+		err = w.writeTextCell(i, name, xlsxStyleHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.sheet.WriteString("</row>")
+	return err
+}
+
+// Write adds the row of the transaction. The rows are buffered, and the error of the underlying
+// io.Writer, such as a closed connection, is returned by the first call after it occurs,
+// so the export can be stopped instead of writing the rest of the rows in vain.
+func (w *TransactionXlsxWriter) Write(transaction Transaction) error {
+	if w.rowCount >= w.maxRows {
+		err := w.endSheet()
+		if err != nil {
+			return err
+		}
+
+		err = w.startSheet()
+		if err != nil {
+			return err
+		}
+	}
+
+	record := transaction.toCsvRecord(&DefaultCsvDialect)
+	err := w.startRow()
+	if err != nil {
+		return err
+	}
+
+	for i, index := range w.columns {
+		column := transactionXlsxColumns[index]
+		value := record[index]
+		switch {
+		case column.time != nil:
+			err = w.writeNumberCell(i, formatExcelTime(column.time(&transaction)), column.style)
+		case column.style == xlsxStyleInteger && !isExactExcelInteger(value):
+			err = w.writeTextCell(i, value, xlsxStyleText)
+		case column.style == xlsxStyleText:
+			err = w.writeTextCell(i, value, column.style)
+		default:
+			err = w.writeNumberCell(i, value, column.style)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.sheet.WriteString("</row>")
+	return err
+}
+
+// Flush writes the buffered rows to the underlying io.Writer.
+func (w *TransactionXlsxWriter) Flush() error {
+	err := w.sheet.Flush()
+	if err != nil {
+		return err
+	}
+
+	return w.archive.Flush()
+}
+
+// Close writes the parts of the workbook which follow the rows. It does not
+// close the underlying io.Writer.
+func (w *TransactionXlsxWriter) Close() error {
+	err := w.endSheet()
+	if err != nil {
+		return err
+	}
+
+	contentTypes := strings.Builder{}
+	relationships := strings.Builder{}
+	sheets := strings.Builder{}
+	filters := strings.Builder{}
+	lastColumn := xlsxColumnName(len(w.columns) - 1)
+	for i, rowCount := range w.sheetRowCounts {
+		number := strconv.Itoa(i + 1)
+		name := xlsxSheetName
+		if i > 0 {
+			name += " " + number
+		}
+
+		contentTypes.WriteString(
+			`<Override PartName="/xl/worksheets/sheet` + number + `.xml" ` +
+				`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`,
+		)
+		relationships.WriteString(
+			`<Relationship Id="rId` + number + `" Type="` + xlsxRelsNs + `/worksheet" Target="worksheets/sheet` + number + `.xml"/>`,
+		)
+		sheets.WriteString(`<sheet name="` + name + `" sheetId="` + number + `" r:id="rId` + number + `"/>`)
+		filters.WriteString(
+			`<definedName name="_xlnm._FilterDatabase" localSheetId="` + strconv.Itoa(i) + `" hidden="1">'` +
+				name + `'!$A$1:$` + lastColumn + `$` + strconv.Itoa(rowCount) + `</definedName>`,
+		)
+	}
+
+	stylesId := strconv.Itoa(len(w.sheetRowCounts) + 1)
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "[Content_Types].xml",
+			content: xlsxHeader +
+				`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+				`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+				`<Default Extension="xml" ContentType="application/xml"/>` +
+				`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+				contentTypes.String() +
+				`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+				`</Types>`,
+		},
+		{
+			name: "xl/_rels/workbook.xml.rels",
+			content: xlsxHeader +
+				`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				relationships.String() +
+				`<Relationship Id="rId` + stylesId + `" Type="` + xlsxRelsNs + `/styles" Target="styles.xml"/>` +
+				`</Relationships>`,
+		},
+		{
+			name: "xl/workbook.xml",
+			content: xlsxHeader + `<workbook xmlns="` + xlsxMainNs + `" xmlns:r="` + xlsxRelsNs + `">` +
+				`<sheets>` + sheets.String() + `</sheets>` +
+				`<definedNames>` + filters.String() + `</definedNames>` +
+				`</workbook>`,
+		},
+	}
+	for _, part := range parts {
+		err = w.writePart(part.name, part.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.archive.Close()
+}
+
+// endSheet writes the autofilter of the current sheet, which is completed.
+func (w *TransactionXlsxWriter) endSheet() error {
+	lastCell := xlsxColumnName(len(w.columns)-1) + strconv.Itoa(w.rowCount)
+	_, err := w.sheet.WriteString(`</sheetData><autoFilter ref="A1:` + lastCell + `"/></worksheet>`)
+	if err != nil {
+		return err
+	}
+
+	err = w.sheet.Flush()
+	if err != nil {
+		return err
+	}
+
+	w.sheetRowCounts = append(w.sheetRowCounts, w.rowCount)
+	return nil
+}
+
+func (w *TransactionXlsxWriter) writePart(name, content string) error {
+	part, err := w.archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(part, content)
+	return err
+}
+
+func (w *TransactionXlsxWriter) startRow() error {
+	w.rowCount++
+	_, err := fmt.Fprintf(w.sheet, `<row r="%d">`, w.rowCount)
+	return err
+}
+
+func (w *TransactionXlsxWriter) writeNumberCell(column int, value string, style int) error {
+	_, err := fmt.Fprintf(w.sheet, `<c r="%s%d" s="%d"><v>%s</v></c>`, xlsxColumnName(column), w.rowCount, style, value)
+	return err
+}
+
+func (w *TransactionXlsxWriter) writeTextCell(column int, value string, style int) error {
+	_, err := fmt.Fprintf(
+		w.sheet, `<c r="%s%d" s="%d" t="inlineStr"><is><t xml:space="preserve">`,
+		xlsxColumnName(column), w.rowCount, style,
+	)
+	if err != nil {
+		return err
+	}
+
+	// characters which are not allowed in XML are replaced
+	err = xml.EscapeText(w.sheet, []byte(value))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.sheet.WriteString("</t></is></c>")
+	return err
+}
+
+// xlsxColumnName returns the letters of the column with the zero-based index.
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+
+	return name
+}
+
+// isExactExcelInteger returns true if Excel shows the integer without rounding.
+func isExactExcelInteger(value string) bool {
+	number, err := strconv.ParseUint(value, 10, 64)
+	return err == nil && number <= xlsxMaxExactInteger
+}
+
+// formatExcelTime returns the time as the number of days since the Excel epoch.
+// Excel has no time zones, so the clock time is kept as is.
+func formatExcelTime(t time.Time) string {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	clock := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	seconds := float64(clock.Unix()-epoch.Unix()) + float64(clock.Nanosecond())/float64(time.Second)
+	return strconv.FormatFloat(seconds/(24*60*60), 'f', -1, 64)
+}
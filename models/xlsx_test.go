@@ -0,0 +1,221 @@
+package models
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransactionXlsxWriter_Write(t *testing.T) {
+	transaction, err := NewTransactionCsvDialectReader(strings.NewReader(excelUaTestRow), CsvDialectPresets["excel-ua"]).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transaction.PaymentNarrative = "A & B <c>"
+	parts := writeTestXlsx(t, nil, transaction)
+	for name, content := range parts {
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			_, err = decoder.Token()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("invalid XML in %s: %v", name, err)
+			}
+		}
+	}
+
+	// the content types and the relationships refer to the written parts
+	references := regexp.MustCompile(`PartName="/([^"]+)"|Target="([^"]+)"`)
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels"} {
+		for _, match := range references.FindAllStringSubmatch(parts[name], -1) {
+			part := match[1]
+			if part == "" && name == "_rels/.rels" {
+				part = match[2]
+			} else if part == "" {
+				part = "xl/" + match[2]
+			}
+
+			if _, ok := parts[part]; !ok {
+				t.Errorf("%s refers to the missing part %s", name, part)
+			}
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	expectedCells := []string{
+		`<c r="A1" s="4" t="inlineStr"><is><t xml:space="preserve">TransactionId</t></is></c>`,
+		`<c r="A2" s="1"><v>1</v></c>`,
+		`<c r="E2" s="2"><v>1.00</v></c>`,
+		`<c r="I2" s="2"><v>-0.01</v></c>`,
+		`<c r="J2" s="3"><v>44785.476006944446</v></c>`,
+		`<c r="N2" s="5" t="inlineStr"><is><t xml:space="preserve">PS16698205</t></is></c>`,
+		`<c r="T2" s="5" t="inlineStr"><is><t xml:space="preserve">UA713451373919523</t></is></c>`,
+		`<c r="U2" s="5" t="inlineStr"><is><t xml:space="preserve">A &amp; B &lt;c&gt;</t></is></c>`,
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`,
+		`<autoFilter ref="A1:U2"/>`,
+	}
+	for _, cell := range expectedCells {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("expected %s in the sheet:\n%s", cell, sheet)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], "'Transactions'!$A$1:$U$2") {
+		t.Errorf("expected the filter range in the workbook:\n%s", parts["xl/workbook.xml"])
+	}
+}
+
+func TestTransactionXlsxWriter_Write_Projection(t *testing.T) {
+	projection, err := ParseTransactionProjection("DatePost,TransactionId")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the id is written as text, because Excel would round it
+	transaction := Transaction{Id: 12345678901234567, DatePost: time.Date(2022, 8, 12, 12, 0, 0, 0, time.UTC)}
+	sheet := writeTestXlsx(t, projection, transaction)["xl/worksheets/sheet1.xml"]
+	expectedCells := []string{
+		`<c r="A1" s="4" t="inlineStr"><is><t xml:space="preserve">DatePost</t></is></c>`,
+		`<c r="A2" s="3"><v>44785.5</v></c>`,
+		`<c r="B2" s="5" t="inlineStr"><is><t xml:space="preserve">12345678901234567</t></is></c>`,
+		`<autoFilter ref="A1:B2"/>`,
+	}
+	for _, cell := range expectedCells {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("expected %s in the sheet:\n%s", cell, sheet)
+		}
+	}
+
+	if strings.Contains(sheet, `r="C1"`) {
+		t.Errorf("expected 2 columns in the sheet:\n%s", sheet)
+	}
+}
+
+func TestTransactionXlsxWriter_Write_RowLimit(t *testing.T) {
+	transactions := []Transaction{{Id: 1}, {Id: 2}, {Id: 3}}
+	tests := []struct {
+		name           string
+		maxRows        int
+		expectedSheets []string
+	}{
+		// the header is counted as a row of every sheet
+		{"BelowLimit", 5, []string{`<autoFilter ref="A1:A4"/>`}},
+		{"AtLimit", 4, []string{`<autoFilter ref="A1:A4"/>`}},
+		{"AboveLimit", 3, []string{`<autoFilter ref="A1:A3"/>`, `<c r="A2" s="1"><v>3</v></c></row></sheetData><autoFilter ref="A1:A2"/>`}},
+		{"SingleRowSheets", 2, []string{`<v>1</v>`, `<v>2</v>`, `<v>3</v>`}},
+	}
+	projection, err := ParseTransactionProjection("TransactionId")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				buffer := bytes.Buffer{}
+				writer := NewTransactionXlsxWriter(&buffer)
+				writer.maxRows = test.maxRows
+				writer.SetProjection(projection)
+				parts := readTestXlsx(t, writer, &buffer, transactions)
+				for i, expected := range test.expectedSheets {
+					name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+					if !strings.Contains(parts[name], expected) || !strings.Contains(parts[name], `<c r="A1" s="4"`) {
+						t.Errorf("expected %s with the header in %s, actual:\n%s", expected, name, parts[name])
+					}
+
+					if !strings.Contains(parts["[Content_Types].xml"], "/"+name) {
+						t.Errorf("expected %s in the content types", name)
+					}
+				}
+
+				if _, ok := parts[fmt.Sprintf("xl/worksheets/sheet%d.xml", len(test.expectedSheets)+1)]; ok {
+					t.Errorf("expected %d sheets", len(test.expectedSheets))
+				}
+
+				if len(test.expectedSheets) > 1 && !strings.Contains(parts["xl/workbook.xml"], `<sheet name="Transactions 2" sheetId="2" r:id="rId2"/>`) {
+					t.Errorf("expected the second sheet in the workbook:\n%s", parts["xl/workbook.xml"])
+				}
+			},
+		)
+	}
+}
+
+func TestTransactionXlsxWriter_Write_ClosedWriter(t *testing.T) {
+	writer := NewTransactionXlsxWriter(closedTestWriter{})
+	err := writer.WriteHeader()
+	for i := uint64(1); err == nil && i <= 100000; i++ {
+		err = writer.Write(Transaction{Id: i, PaymentNarrative: "narrative"})
+	}
+
+	if err != io.ErrClosedPipe {
+		t.Errorf("expected %v, actual %v", io.ErrClosedPipe, err)
+	}
+}
+
+// closedTestWriter fails like the writer of a closed connection.
+type closedTestWriter struct{}
+
+func (closedTestWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// writeTestXlsx returns the contents of the workbook parts by their names.
+func writeTestXlsx(t *testing.T, projection TransactionProjection, transactions ...Transaction) map[string]string {
+	buffer := bytes.Buffer{}
+	writer := NewTransactionXlsxWriter(&buffer)
+	writer.SetProjection(projection)
+	return readTestXlsx(t, writer, &buffer, transactions)
+}
+
+// readTestXlsx writes the transactions to the buffer of the writer and returns the workbook parts.
+func readTestXlsx(t *testing.T, writer *TransactionXlsxWriter, buffer *bytes.Buffer, transactions []Transaction) map[string]string {
+	err := writer.WriteHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transaction := range transactions {
+		err = writer.Write(transaction)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts[file.Name] = string(content)
+	}
+
+	return parts
+}
@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"TraineeGolangTestTask/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAlreadyReverted = errors.New("import is already reverted")
+
+// RevertResult contains counts of transactions affected by reverting an import.
+type RevertResult struct {
+	// Deleted is the count of transactions created by the import.
+	Deleted int
+
+	// Restored is the count of transactions updated by the import,
+	// which got back the values they had before the import.
+	Restored int
+}
+
+type ImportRepository interface {
+	Create(record *models.Import) error
+	Save(record *models.Import) error
+
+	// FindById returns ErrNotFound if the import does not exist.
+	FindById(id uint64) (*models.Import, error)
+
+	// FindByChecksum returns the latest import of the file with the given
+	// checksum which is not reverted, or ErrNotFound.
+	FindByChecksum(checksum string) (*models.Import, error)
+
+	// List returns paginated imports, the latest ones first.
+	List(page, pageSize int) ([]models.Import, error)
+
+	// Delete reverts changes of the import like Revert and removes the import itself.
+	// Transactions updated by a later import no longer refer to the deleted one
+	// when that import is reverted.
+	Delete(id uint64) (RevertResult, error)
+
+	// Revert removes transactions created by the import, restores transactions
+	// updated by it and marks it as reverted, the import itself is kept.
+	// Transactions updated by a later import are left as they are.
+	// ErrAlreadyReverted is returned if it was reverted before.
+	Revert(id uint64) (RevertResult, error)
+}
+
+type ImportRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewImportRepository(db *gorm.DB) *ImportRepositoryImpl {
+	return &ImportRepositoryImpl{db: db}
+}
+
+func (r *ImportRepositoryImpl) Create(record *models.Import) error {
+	return r.db.Create(record).Error
+}
+
+func (r *ImportRepositoryImpl) Save(record *models.Import) error {
+	return r.db.Save(record).Error
+}
+
+func (r *ImportRepositoryImpl) FindById(id uint64) (*models.Import, error) {
+	return findImportById(r.db, id)
+}
+
+func (r *ImportRepositoryImpl) FindByChecksum(checksum string) (*models.Import, error) {
+	record := &models.Import{}
+	err := r.db.Where("checksum = ? AND reverted_at IS NULL", checksum).Order("id DESC").First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (r *ImportRepositoryImpl) List(page, pageSize int) ([]models.Import, error) {
+	var imports []models.Import
+	tx := r.db.Order("id DESC")
+	if page > 0 && pageSize > 0 {
+		tx = tx.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	err := tx.Find(&imports).Error
+	return imports, err
+}
+
+func (r *ImportRepositoryImpl) Delete(id uint64) (RevertResult, error) {
+	result := RevertResult{}
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			_, err := findImportById(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+			if err != nil {
+				return err
+			}
+
+			result, err = revertTransactions(tx, id)
+			if err != nil {
+				return err
+			}
+
+			// the snapshots taken by later imports should not restore the references to the deleted import
+			err = tx.Model(&models.TransactionSnapshot{}).Where("import_id = ?", id).Update("import_id", nil).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Delete(&models.Import{}, id).Error
+		},
+	)
+	return result, err
+}
+
+func (r *ImportRepositoryImpl) Revert(id uint64) (RevertResult, error) {
+	result := RevertResult{}
+	err := r.db.Transaction(
+		func(tx *gorm.DB) error {
+			record, err := findImportById(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+			if err != nil {
+				return err
+			}
+
+			if record.RevertedAt != nil {
+				return ErrAlreadyReverted
+			}
+
+			result, err = revertTransactions(tx, id)
+			if err != nil {
+				return err
+			}
+
+			revertedAt := time.Now()
+			record.RevertedAt = &revertedAt
+			return tx.Save(record).Error
+		},
+	)
+	return result, err
+}
+
+// revertTransactions restores transactions updated by the import from their snapshots
+// and deletes transactions created by it. The transactions which refer to another
+// import were updated later, so they are kept.
+func revertTransactions(tx *gorm.DB, id uint64) (RevertResult, error) {
+	statement := &gorm.Statement{DB: tx}
+	err := statement.Parse(&models.Transaction{})
+	if err != nil {
+		return RevertResult{}, err
+	}
+
+	snapshotStatement := &gorm.Statement{DB: tx}
+	err = snapshotStatement.Parse(&models.TransactionSnapshot{})
+	if err != nil {
+		return RevertResult{}, err
+	}
+
+	updates := make([]string, 0, len(statement.Schema.DBNames))
+	for _, name := range statement.Schema.DBNames {
+		if name != "id" {
+			column := statement.Quote(name)
+			updates = append(updates, fmt.Sprintf("%s = s.%s", column, column))
+		}
+	}
+
+	snapshotTable := statement.Quote(clause.Table{Name: snapshotStatement.Schema.Table})
+	restored := tx.Exec(
+		fmt.Sprintf(
+			"UPDATE %s AS t SET %s FROM %s AS s "+
+				"WHERE s.updated_by_import_id = ? AND t.id = s.id AND t.import_id = ?",
+			statement.Quote(clause.Table{Name: statement.Schema.Table}),
+			strings.Join(updates, ", "),
+			snapshotTable,
+		),
+		id,
+		id,
+	)
+	if restored.Error != nil {
+		return RevertResult{}, restored.Error
+	}
+
+	// the restored transactions refer to the previous imports, so only the created ones are left
+	deleted := tx.Where("import_id = ?", id).Delete(&models.Transaction{})
+	if deleted.Error != nil {
+		return RevertResult{}, deleted.Error
+	}
+
+	err = tx.Where("updated_by_import_id = ?", id).Delete(&models.TransactionSnapshot{}).Error
+	if err != nil {
+		return RevertResult{}, err
+	}
+
+	return RevertResult{Deleted: int(deleted.RowsAffected), Restored: int(restored.RowsAffected)}, nil
+}
+
+func findImportById(tx *gorm.DB, id uint64) (*models.Import, error) {
+	record := &models.Import{}
+	err := tx.Where("id = ?", id).First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
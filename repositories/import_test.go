@@ -0,0 +1,306 @@
+package repositories
+
+import (
+	"testing"
+
+	"TraineeGolangTestTask/models"
+)
+
+const (
+	testImportFileName       = "test.csv"
+	testUpdateImportFileName = "update.csv"
+)
+
+func TestImportRepositoryImpl_DeleteAndRevert(t *testing.T) {
+	db, err := openTestDb()
+	if err != nil {
+		t.Error(err)
+	}
+
+	db.Exec("CREATE SCHEMA rest_api")
+	_ = models.MigrateAll(db)
+
+	deleteTestTransactions := func() {
+		for _, tr := range testTransactions {
+			db.Delete(&tr, "id = ?", tr.Id)
+			db.Delete(&models.TransactionSnapshot{}, "id = ?", tr.Id)
+		}
+
+		db.Delete(&models.Import{}, "file_name IN ?", []string{testImportFileName, testUpdateImportFileName})
+	}
+
+	defer deleteTestTransactions()
+
+	repo := &TransactionRepositoryImpl{db: db}
+
+	t.Run(
+		"Revert", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_Revert(t, repo)
+		},
+	)
+
+	t.Run(
+		"RevertUpdated", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_RevertUpdated(t, repo, repo.CreateBatch)
+		},
+	)
+
+	t.Run(
+		"RevertUpdatedCopy", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_RevertUpdated(t, repo, repo.CopyBatch)
+		},
+	)
+
+	t.Run(
+		"DeleteUpdated", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_DeleteUpdated(t, repo)
+		},
+	)
+
+	t.Run(
+		"DeleteOverwritten", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_DeleteOverwritten(t, repo)
+		},
+	)
+
+	t.Run(
+		"FindByChecksum", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_FindByChecksum(t, repo)
+		},
+	)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestImportRepositoryImpl_Delete(t, repo)
+		},
+	)
+}
+
+func SubTestImportRepositoryImpl_Revert(t *testing.T, repo *TransactionRepositoryImpl) {
+	record := createTestImport(t, repo)
+	result, err := repo.Imports().Revert(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Deleted != len(testTransactions) {
+		t.Errorf("expected deleted count %d, actual %d", len(testTransactions), result.Deleted)
+	}
+
+	reverted, err := repo.Imports().FindById(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reverted.RevertedAt == nil {
+		t.Error("import is not marked as reverted")
+	}
+
+	_, err = repo.Imports().Revert(record.Id)
+	if err != ErrAlreadyReverted {
+		t.Errorf("expected %v, actual %v", ErrAlreadyReverted, err)
+	}
+}
+
+func SubTestImportRepositoryImpl_RevertUpdated(
+	t *testing.T,
+	repo *TransactionRepositoryImpl,
+	saveTransactions func([]models.Transaction, ConflictStrategy) (BatchResult, error),
+) {
+	// the first transaction exists before the import, the import updates it and creates the rest
+	seeded := testTransactions[:1]
+	_, err := repo.CreateBatch(seeded, ConflictError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := createTestUpdateImport(t, repo, saveTransactions)
+	result, err := repo.Imports().Revert(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := RevertResult{Deleted: len(testTransactions) - len(seeded), Restored: len(seeded)}
+	if result != expected {
+		t.Errorf("expected %+v, actual %+v", expected, result)
+	}
+
+	assertTestTransactionsAreSeeded(t, repo, seeded)
+}
+
+func SubTestImportRepositoryImpl_DeleteUpdated(t *testing.T, repo *TransactionRepositoryImpl) {
+	seeded := testTransactions
+	_, err := repo.CreateBatch(seeded, ConflictError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := createTestUpdateImport(t, repo, repo.CreateBatch)
+	result, err := repo.Imports().Delete(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := RevertResult{Restored: len(seeded)}
+	if result != expected {
+		t.Errorf("expected %+v, actual %+v", expected, result)
+	}
+
+	assertTestTransactionsAreSeeded(t, repo, seeded)
+}
+
+// SubTestImportRepositoryImpl_DeleteOverwritten deletes an import whose transactions were updated
+// by a later import, so reverting the later import should not restore references to the deleted one.
+func SubTestImportRepositoryImpl_DeleteOverwritten(t *testing.T, repo *TransactionRepositoryImpl) {
+	record := createTestImport(t, repo)
+	update := createTestUpdateImport(t, repo, repo.CreateBatch)
+	result, err := repo.Imports().Delete(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != (RevertResult{}) {
+		t.Errorf("expected no changed transactions, actual %+v", result)
+	}
+
+	result, err = repo.Imports().Revert(update.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := RevertResult{Restored: len(testTransactions)}
+	if result != expected {
+		t.Errorf("expected %+v, actual %+v", expected, result)
+	}
+
+	assertTestTransactionsAreSeeded(t, repo, testTransactions)
+}
+
+func SubTestImportRepositoryImpl_FindByChecksum(t *testing.T, repo *TransactionRepositoryImpl) {
+	record := createTestImport(t, repo)
+	record.Checksum = "test-checksum"
+	err := repo.Imports().Save(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.Imports().FindByChecksum(record.Checksum)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found.Id != record.Id {
+		t.Errorf("expected import %d, actual %d", record.Id, found.Id)
+	}
+
+	_, err = repo.Imports().Revert(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Imports().FindByChecksum(record.Checksum)
+	if err != ErrNotFound {
+		t.Errorf("expected %v, actual %v", ErrNotFound, err)
+	}
+}
+
+func SubTestImportRepositoryImpl_Delete(t *testing.T, repo *TransactionRepositoryImpl) {
+	record := createTestImport(t, repo)
+	result, err := repo.Imports().Delete(record.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Deleted != len(testTransactions) {
+		t.Errorf("expected deleted count %d, actual %d", len(testTransactions), result.Deleted)
+	}
+
+	_, err = repo.Imports().FindById(record.Id)
+	if err != ErrNotFound {
+		t.Errorf("expected %v, actual %v", ErrNotFound, err)
+	}
+}
+
+func createTestImport(t *testing.T, repo *TransactionRepositoryImpl) *models.Import {
+	record := &models.Import{FileName: testImportFileName}
+	err := repo.Imports().Create(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := make([]models.Transaction, len(testTransactions))
+	for i, transaction := range testTransactions {
+		transaction.ImportId = &record.Id
+		transactions[i] = transaction
+	}
+
+	_, err = repo.CreateBatch(transactions, ConflictError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+// createTestUpdateImport saves all test transactions with a changed narrative by an import
+// which updates the existing transactions.
+func createTestUpdateImport(
+	t *testing.T,
+	repo *TransactionRepositoryImpl,
+	saveTransactions func([]models.Transaction, ConflictStrategy) (BatchResult, error),
+) *models.Import {
+	record := &models.Import{FileName: testUpdateImportFileName}
+	err := repo.Imports().Create(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions := make([]models.Transaction, len(testTransactions))
+	for i, transaction := range testTransactions {
+		transaction.ImportId = &record.Id
+		transaction.PaymentNarrative = "updated"
+		transactions[i] = transaction
+	}
+
+	_, err = saveTransactions(transactions, ConflictUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return record
+}
+
+func assertTestTransactionsAreSeeded(t *testing.T, repo *TransactionRepositoryImpl, seeded []models.Transaction) {
+	var transactions []models.Transaction
+	err := repo.db.Where("id IN ?", getIds(testTransactions)).Order("id").Find(&transactions).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transactions) != len(seeded) {
+		t.Fatalf("expected %d transactions, actual %d", len(seeded), len(transactions))
+	}
+
+	for i, transaction := range transactions {
+		if transaction.Id != seeded[i].Id || transaction.PaymentNarrative != seeded[i].PaymentNarrative {
+			t.Errorf("expected %+v, actual %+v", seeded[i], transaction)
+		}
+
+		if transaction.ImportId != nil {
+			t.Errorf("expected no import of the seeded transaction, actual %d", *transaction.ImportId)
+		}
+	}
+}
+
+func TestNewImportRepository_IsNotNil(t *testing.T) {
+	if NewImportRepository(nil) == nil {
+		t.Error("import repository is nil")
+	}
+}
@@ -1,27 +1,90 @@
 package repositories
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"TraineeGolangTestTask/models"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
+// copyStagingTable is a temporary table which receives rows sent by
+// CopyBatch. It is dropped at the end of the database transaction.
+const copyStagingTable = "transactions_staging"
+
+// ConflictStrategy defines what happens when a created transaction
+// has the same id as an existing one.
+type ConflictStrategy string
+
+const (
+	ConflictError  ConflictStrategy = "error"
+	ConflictSkip   ConflictStrategy = "skip"
+	ConflictUpdate ConflictStrategy = "update"
+)
+
+func ParseConflictStrategy(value string) (ConflictStrategy, error) {
+	switch strategy := ConflictStrategy(value); strategy {
+	case ConflictError, ConflictSkip, ConflictUpdate:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf(
+			"conflict strategy should be one of \"%v\", \"%v\" or \"%v\"",
+			ConflictError,
+			ConflictSkip,
+			ConflictUpdate,
+		)
+	}
+}
+
+// BatchResult contains counts of transactions affected by CreateBatch.
+type BatchResult struct {
+	Inserted int
+	Skipped  int
+	Updated  int
+}
+
+func (r *BatchResult) Add(other BatchResult) {
+	r.Inserted += other.Inserted
+	r.Skipped += other.Skipped
+	r.Updated += other.Updated
+}
+
 type TransactionRepository interface {
 	Create(model models.Transaction) error
-	CreateBatch(models []models.Transaction) error
+	CreateBatch(models []models.Transaction, onConflict ConflictStrategy) (BatchResult, error)
+
+	// CopyBatch does the same as CreateBatch, but it is faster for large batches.
+	CopyBatch(models []models.Transaction, onConflict ConflictStrategy) (BatchResult, error)
+	FindExistingIds(ids []uint64) ([]uint64, error)
 	UseTransaction(dbTransaction func(TransactionRepository) error) error
-	Filter(filters []TransactionFilter, page, pageSize int) []models.Transaction
-	ForEach(filters []TransactionFilter, apply func(model models.Transaction) error) error
+
+	// Filter and ForEach load only the given fields of the transactions,
+	// all fields are loaded if fields is nil.
+	Filter(filters []TransactionFilter, fields []string, page, pageSize int) []models.Transaction
+	ForEach(filters []TransactionFilter, fields []string, apply func(model models.Transaction) error) error
+
+	// Imports returns the repository of imports which uses the same
+	// database connection or transaction.
+	Imports() ImportRepository
 
 	NewFilterBuilder() TransactionFilterBuilder
 }
 
 type TransactionRepositoryImpl struct {
 	db *gorm.DB
+
+	// conn is the connection of the database transaction started by
+	// UseTransaction, it is nil outside of a database transaction.
+	conn *sql.Conn
 }
 
 func NewTransactionRepository(db *gorm.DB) *TransactionRepositoryImpl {
@@ -33,24 +96,343 @@ func (tr *TransactionRepositoryImpl) Create(model models.Transaction) error {
 	return tx.Error
 }
 
-func (tr *TransactionRepositoryImpl) CreateBatch(models []models.Transaction) error {
-	tx := tr.db.Create(&models)
-	return tx.Error
+// CreateBatch inserts transactions with a single statement. Depending on onConflict,
+// the statement fails, or transactions with existing ids are skipped or updated.
+func (tr *TransactionRepositoryImpl) CreateBatch(
+	transactions []models.Transaction,
+	onConflict ConflictStrategy,
+) (BatchResult, error) {
+	switch onConflict {
+	case ConflictSkip:
+		tx := tr.db.Clauses(
+			clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoNothing: true,
+			},
+		).Create(&transactions)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		inserted := int(tx.RowsAffected)
+		return BatchResult{Inserted: inserted, Skipped: len(transactions) - inserted}, nil
+	case ConflictUpdate:
+		// a single statement cannot update the same row twice, so only the last
+		// occurrence of the id is kept, it overrides the previous ones anyway
+		transactions, duplicatesCount := removeDuplicateIds(transactions)
+		existingIds, err := tr.FindExistingIds(getIds(transactions))
+		if err != nil {
+			return BatchResult{}, err
+		}
+
+		if len(existingIds) > 0 {
+			err = tr.saveSnapshots(getImportId(transactions), existingIds)
+			if err != nil {
+				return BatchResult{}, err
+			}
+		}
+
+		tx := tr.db.Clauses(
+			clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				UpdateAll: true,
+			},
+		).Create(&transactions)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		return BatchResult{
+			Inserted: len(transactions) - len(existingIds),
+			Updated:  len(existingIds) + duplicatesCount,
+		}, nil
+	default:
+		tx := tr.db.Create(&transactions)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		return BatchResult{Inserted: len(transactions)}, nil
+	}
 }
 
+// CopyBatch sends transactions to a temporary staging table using the COPY protocol,
+// then they are merged into the transactions table with a single statement. The
+// conflicts are resolved the same way as in CreateBatch. The staging table adds some
+// overhead, so CreateBatch is faster for batches of a few thousand rows.
+func (tr *TransactionRepositoryImpl) CopyBatch(
+	transactions []models.Transaction,
+	onConflict ConflictStrategy,
+) (BatchResult, error) {
+	if tr.conn == nil {
+		result := BatchResult{}
+		err := tr.UseTransaction(
+			func(repository TransactionRepository) (err error) {
+				result, err = repository.CopyBatch(transactions, onConflict)
+				return err
+			},
+		)
+		return result, err
+	}
+
+	statement := &gorm.Statement{DB: tr.db}
+	err := statement.Parse(&models.Transaction{})
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	table := statement.Quote(clause.Table{Name: statement.Schema.Table})
+	err = tr.db.Exec(
+		fmt.Sprintf(
+			"CREATE TEMPORARY TABLE IF NOT EXISTS %s (LIKE %s, staging_row BIGSERIAL) ON COMMIT DROP",
+			copyStagingTable,
+			table,
+		),
+	).Error
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	err = tr.db.Exec("TRUNCATE " + copyStagingTable).Error
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	err = tr.copyToStagingTable(statement, transactions)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	return tr.mergeStagingTable(statement, table, len(transactions), getImportId(transactions), onConflict)
+}
+
+func (tr *TransactionRepositoryImpl) copyToStagingTable(
+	statement *gorm.Statement,
+	transactions []models.Transaction,
+) error {
+	ctx := tr.db.Statement.Context
+	fields := make([]*schema.Field, len(statement.Schema.DBNames))
+	for i, name := range statement.Schema.DBNames {
+		fields[i] = statement.Schema.FieldsByDBName[name]
+	}
+
+	return tr.conn.Raw(
+		func(driverConn interface{}) error {
+			conn, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return fmt.Errorf("copying is not supported by the %T driver", driverConn)
+			}
+
+			_, err := conn.Conn().CopyFrom(
+				ctx,
+				pgx.Identifier{copyStagingTable},
+				statement.Schema.DBNames,
+				pgx.CopyFromSlice(
+					len(transactions), func(i int) ([]interface{}, error) {
+						row := reflect.ValueOf(&transactions[i]).Elem()
+						values := make([]interface{}, len(fields))
+						for j, field := range fields {
+							values[j], _ = field.ValueOf(ctx, row)
+						}
+
+						return values, nil
+					},
+				),
+			)
+			return err
+		},
+	)
+}
+
+func (tr *TransactionRepositoryImpl) mergeStagingTable(
+	statement *gorm.Statement,
+	table string,
+	count int,
+	importId *uint64,
+	onConflict ConflictStrategy,
+) (BatchResult, error) {
+	columns := make([]string, len(statement.Schema.DBNames))
+	for i, name := range statement.Schema.DBNames {
+		columns[i] = statement.Quote(name)
+	}
+
+	columnList := strings.Join(columns, ", ")
+	switch onConflict {
+	case ConflictSkip:
+		tx := tr.db.Exec(
+			fmt.Sprintf(
+				"INSERT INTO %s (%s) SELECT %s FROM %s ORDER BY staging_row ON CONFLICT (id) DO NOTHING",
+				table,
+				columnList,
+				columnList,
+				copyStagingTable,
+			),
+		)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		inserted := int(tx.RowsAffected)
+		return BatchResult{Inserted: inserted, Skipped: count - inserted}, nil
+	case ConflictUpdate:
+		counts := struct {
+			DistinctCount int
+			ExistingCount int
+		}{}
+		tx := tr.db.Raw(
+			fmt.Sprintf(
+				"SELECT (SELECT COUNT(DISTINCT id) FROM %s) AS distinct_count, "+
+					"(SELECT COUNT(*) FROM %s WHERE id IN (SELECT id FROM %s)) AS existing_count",
+				copyStagingTable,
+				table,
+				copyStagingTable,
+			),
+		).Scan(&counts)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		err := tr.saveSnapshots(importId, tr.db.Table(copyStagingTable).Select("id"))
+		if err != nil {
+			return BatchResult{}, err
+		}
+
+		// like in CreateBatch, only the last occurrence of the id is saved
+		updates := make([]string, 0, len(columns))
+		for _, column := range columns {
+			if column != statement.Quote("id") {
+				updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+			}
+		}
+
+		tx = tr.db.Exec(
+			fmt.Sprintf(
+				"INSERT INTO %s (%s) SELECT DISTINCT ON (id) %s FROM %s ORDER BY id, staging_row DESC "+
+					"ON CONFLICT (id) DO UPDATE SET %s",
+				table,
+				columnList,
+				columnList,
+				copyStagingTable,
+				strings.Join(updates, ", "),
+			),
+		)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		return BatchResult{
+			Inserted: counts.DistinctCount - counts.ExistingCount,
+			Updated:  counts.ExistingCount + count - counts.DistinctCount,
+		}, nil
+	default:
+		tx := tr.db.Exec(
+			fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", table, columnList, columnList, copyStagingTable),
+		)
+		if tx.Error != nil {
+			return BatchResult{}, tx.Error
+		}
+
+		return BatchResult{Inserted: int(tx.RowsAffected)}, nil
+	}
+}
+
+// saveSnapshots keeps the transactions with the given ids, which are about to be updated
+// by the import, see models.TransactionSnapshot. The ids are either a slice or a subquery.
+// Transactions which were created or updated by the import before are skipped, as they
+// did not exist before the import or are already kept.
+func (tr *TransactionRepositoryImpl) saveSnapshots(importId *uint64, ids interface{}) error {
+	if importId == nil {
+		return nil
+	}
+
+	statement := &gorm.Statement{DB: tr.db}
+	err := statement.Parse(&models.Transaction{})
+	if err != nil {
+		return err
+	}
+
+	snapshotStatement := &gorm.Statement{DB: tr.db}
+	err = snapshotStatement.Parse(&models.TransactionSnapshot{})
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(statement.Schema.DBNames))
+	for i, name := range statement.Schema.DBNames {
+		columns[i] = statement.Quote(name)
+	}
+
+	columnList := strings.Join(columns, ", ")
+	return tr.db.Exec(
+		fmt.Sprintf(
+			"INSERT INTO %s (%s, %s) SELECT ?, %s FROM %s WHERE id IN ? AND import_id IS DISTINCT FROM ?",
+			statement.Quote(clause.Table{Name: snapshotStatement.Schema.Table}),
+			statement.Quote("updated_by_import_id"),
+			columnList,
+			columnList,
+			statement.Quote(clause.Table{Name: statement.Schema.Table}),
+		),
+		*importId,
+		ids,
+		*importId,
+	).Error
+}
+
+// FindExistingIds returns those of the given ids which belong to saved transactions.
+func (tr *TransactionRepositoryImpl) FindExistingIds(ids []uint64) ([]uint64, error) {
+	existingIds := []uint64{}
+	if len(ids) == 0 {
+		return existingIds, nil
+	}
+
+	tx := tr.db.Model(&models.Transaction{}).Where("id IN ?", ids).Pluck("id", &existingIds)
+	return existingIds, tx.Error
+}
+
+// UseTransaction starts the database transaction on a dedicated connection,
+// so that CopyBatch is able to use the same connection for copying.
 func (tr *TransactionRepositoryImpl) UseTransaction(dbTransaction func(TransactionRepository) error) error {
-	return tr.db.Transaction(
-		func(tx *gorm.DB) error {
-			return dbTransaction(tr)
+	if tr.conn != nil {
+		return tr.db.Transaction(
+			func(tx *gorm.DB) error {
+				return dbTransaction(&TransactionRepositoryImpl{db: tx, conn: tr.conn})
+			},
+		)
+	}
+
+	return tr.db.Connection(
+		func(db *gorm.DB) error {
+			conn, ok := db.Statement.ConnPool.(*sql.Conn)
+			if !ok {
+				return fmt.Errorf("unexpected connection type %T", db.Statement.ConnPool)
+			}
+
+			return db.Transaction(
+				func(tx *gorm.DB) error {
+					return dbTransaction(&TransactionRepositoryImpl{db: tx, conn: conn})
+				},
+			)
 		},
 	)
 }
 
+func (tr *TransactionRepositoryImpl) Imports() ImportRepository {
+	return NewImportRepository(tr.db)
+}
+
 // Filter returns paginated result that is a list of transactions with applied filters.
 // If page or pageSize is less than or equals to zero, pagination is ignored.
-func (tr *TransactionRepositoryImpl) Filter(filters []TransactionFilter, page, pageSize int) []models.Transaction {
+func (tr *TransactionRepositoryImpl) Filter(
+	filters []TransactionFilter,
+	fields []string,
+	page, pageSize int,
+) []models.Transaction {
 	var transactions []models.Transaction
 	tx := tr.db.Model(&transactions)
+	if fields != nil {
+		tx.Select(fields)
+	}
+
 	applyFilters(tx, filters)
 	if page > 0 && pageSize > 0 {
 		tx.Limit(pageSize).Offset((page - 1) * pageSize)
@@ -62,9 +444,14 @@ func (tr *TransactionRepositoryImpl) Filter(filters []TransactionFilter, page, p
 
 func (tr *TransactionRepositoryImpl) ForEach(
 	filters []TransactionFilter,
+	fields []string,
 	apply func(model models.Transaction) error,
 ) error {
 	tx := tr.db.Model(&models.Transaction{})
+	if fields != nil {
+		tx.Select(fields)
+	}
+
 	applyFilters(tx, filters)
 	rows, err := tx.Rows()
 	if err != nil {
@@ -87,6 +474,44 @@ func (tr *TransactionRepositoryImpl) ForEach(
 	return nil
 }
 
+func getIds(transactions []models.Transaction) []uint64 {
+	ids := make([]uint64, len(transactions))
+	for i, transaction := range transactions {
+		ids[i] = transaction.Id
+	}
+
+	return ids
+}
+
+// getImportId returns the import which saves the batch, all transactions of a batch belong to one import.
+func getImportId(transactions []models.Transaction) *uint64 {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	return transactions[0].ImportId
+}
+
+func removeDuplicateIds(transactions []models.Transaction) ([]models.Transaction, int) {
+	lastIndexes := make(map[uint64]int, len(transactions))
+	for i, transaction := range transactions {
+		lastIndexes[transaction.Id] = i
+	}
+
+	if len(lastIndexes) == len(transactions) {
+		return transactions, 0
+	}
+
+	unique := make([]models.Transaction, 0, len(lastIndexes))
+	for i, transaction := range transactions {
+		if lastIndexes[transaction.Id] == i {
+			unique = append(unique, transaction)
+		}
+	}
+
+	return unique, len(transactions) - len(unique)
+}
+
 func applyFilters(tx *gorm.DB, filters []TransactionFilter) {
 	for _, filter := range filters {
 		filter(tx)
@@ -106,6 +531,7 @@ type TransactionFilterBuilder interface {
 	AddPaymentType(value string) error
 	AddDatePostRange(valueFrom, valueTo string) error
 	AddPaymentNarrative(value string) error
+	AddImportId(value string) error
 	GetFilters() []TransactionFilter
 }
 
@@ -232,6 +658,23 @@ func (tf *TransactionFilterBuilderImpl) AddPaymentNarrative(value string) error
 	return nil
 }
 
+func (tf *TransactionFilterBuilderImpl) AddImportId(value string) error {
+	if value != "" {
+		importId, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		tf.filters = append(
+			tf.filters, func(tx *gorm.DB) {
+				tx.Where("import_id = ?", importId)
+			},
+		)
+	}
+
+	return nil
+}
+
 func (tf *TransactionFilterBuilderImpl) GetFilters() []TransactionFilter {
 	return tf.filters
 }
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -91,7 +92,7 @@ func TestTransactionRepositoryImpl_Filter(t *testing.T) {
 func SubTestTransactionRepositoryImpl_FilterByTransactionId(t *testing.T, repo *TransactionRepositoryImpl) {
 	builder := repo.NewFilterBuilder()
 	_ = builder.AddTransactionId(fmt.Sprintf("%d", testTransactions[0].Id))
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	checkFilterSingleResult(t, transactions, 0)
 }
 
@@ -103,7 +104,7 @@ func SubTestTransactionRepositoryImpl_FilterByTerminalId(t *testing.T, repo *Tra
 			fmt.Sprintf("%d", testTransactions[2].TerminalId),
 		},
 	)
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	expectedLen := 2
 	actualLen := len(transactions)
 	if actualLen != expectedLen {
@@ -122,21 +123,21 @@ func SubTestTransactionRepositoryImpl_FilterByTerminalId(t *testing.T, repo *Tra
 func SubTestTransactionRepositoryImpl_FilterByStatus(t *testing.T, repo *TransactionRepositoryImpl) {
 	builder := repo.NewFilterBuilder()
 	_ = builder.AddStatus(string(testTransactions[1].Status))
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	checkFilterSingleResult(t, transactions, 1)
 }
 
 func SubTestTransactionRepositoryImpl_FilterByPaymentType(t *testing.T, repo *TransactionRepositoryImpl) {
 	builder := repo.NewFilterBuilder()
 	_ = builder.AddPaymentType(string(testTransactions[2].PaymentType))
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	checkFilterSingleResult(t, transactions, 2)
 }
 
 func SubTestTransactionRepositoryImpl_FilterByAddDatePostRange(t *testing.T, repo *TransactionRepositoryImpl) {
 	builder := repo.NewFilterBuilder()
 	_ = builder.AddDatePostRange("2022-08-12 14:25:27", "2022-08-15 13:02:10")
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	expectedLen := 2
 	actualLen := len(transactions)
 	if actualLen != expectedLen {
@@ -155,7 +156,7 @@ func SubTestTransactionRepositoryImpl_FilterByAddDatePostRange(t *testing.T, rep
 func SubTestTransactionRepositoryImpl_FilterByPaymentNarrative(t *testing.T, repo *TransactionRepositoryImpl) {
 	builder := repo.NewFilterBuilder()
 	_ = builder.AddPaymentNarrative("А11/27123 від 19.11.2020 р.")
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	checkFilterSingleResult(t, transactions, 1)
 }
 
@@ -166,7 +167,7 @@ func SubTestTransactionRepositoryImpl_FilterByStatusAndDatePostRange(
 	builder := repo.NewFilterBuilder()
 	_ = builder.AddStatus(string(models.DECLINED))
 	_ = builder.AddDatePostRange("2022-08-12 14:25:27", "2022-08-15 13:02:10")
-	transactions := repo.Filter(builder.GetFilters(), 1, 10)
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 10)
 	checkFilterSingleResult(t, transactions, 1)
 }
 
@@ -174,7 +175,7 @@ func SubTestTransactionRepositoryImpl_FilterIgnorePagination_IncorrectPage(
 	t *testing.T,
 	repo *TransactionRepositoryImpl,
 ) {
-	transactions := repo.Filter([]TransactionFilter{}, -1, 2)
+	transactions := repo.Filter([]TransactionFilter{}, nil, -1, 2)
 	expectedCount := 3
 	actualCount := len(transactions)
 	if actualCount != expectedCount {
@@ -186,7 +187,7 @@ func SubTestTransactionRepositoryImpl_FilterIgnorePagination_IncorrectPageSize(
 	t *testing.T,
 	repo *TransactionRepositoryImpl,
 ) {
-	transactions := repo.Filter([]TransactionFilter{}, 1, 0)
+	transactions := repo.Filter([]TransactionFilter{}, nil, 1, 0)
 	expectedCount := 3
 	actualCount := len(transactions)
 	if actualCount != expectedCount {
@@ -206,6 +207,264 @@ func checkFilterSingleResult(t *testing.T, transactions []models.Transaction, id
 	}
 }
 
+func TestTransactionRepositoryImpl_CreateBatch(t *testing.T) {
+	db, err := openTestDb()
+	if err != nil {
+		t.Error(err)
+	}
+
+	db.Exec("CREATE SCHEMA rest_api")
+	_ = models.MigrateAll(db)
+
+	deleteTestTransactions := func() {
+		for _, tr := range testTransactions {
+			db.Delete(&tr, "id = ?", tr.Id)
+		}
+	}
+
+	defer deleteTestTransactions()
+
+	repo := &TransactionRepositoryImpl{db: db}
+
+	t.Run(
+		"Error", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestTransactionRepositoryImpl_CreateBatchError(t, repo, repo.CreateBatch)
+		},
+	)
+
+	t.Run(
+		"Skip", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestTransactionRepositoryImpl_CreateBatchSkip(t, repo, repo.CreateBatch)
+		},
+	)
+
+	t.Run(
+		"Update", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestTransactionRepositoryImpl_CreateBatchUpdate(t, repo, repo.CreateBatch)
+		},
+	)
+
+	t.Run(
+		"CopyError", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestTransactionRepositoryImpl_CreateBatchError(t, repo, repo.CopyBatch)
+		},
+	)
+
+	t.Run(
+		"CopySkip", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestTransactionRepositoryImpl_CreateBatchSkip(t, repo, repo.CopyBatch)
+		},
+	)
+
+	t.Run(
+		"CopyUpdate", func(t *testing.T) {
+			deleteTestTransactions()
+			SubTestTransactionRepositoryImpl_CreateBatchUpdate(t, repo, repo.CopyBatch)
+		},
+	)
+}
+
+type createBatchFunc func(transactions []models.Transaction, onConflict ConflictStrategy) (BatchResult, error)
+
+func SubTestTransactionRepositoryImpl_CreateBatchError(
+	t *testing.T,
+	repo *TransactionRepositoryImpl,
+	createBatch createBatchFunc,
+) {
+	result, err := createBatch(testTransactions[:1], ConflictError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBatchResult(t, result, BatchResult{Inserted: 1})
+	_, err = createBatch(testTransactions, ConflictError)
+	if err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func SubTestTransactionRepositoryImpl_CreateBatchSkip(
+	t *testing.T,
+	repo *TransactionRepositoryImpl,
+	createBatch createBatchFunc,
+) {
+	existing := testTransactions[0]
+	existing.PayeeName = "existing"
+	_, err := repo.CreateBatch([]models.Transaction{existing}, ConflictError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := createBatch(testTransactions, ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBatchResult(t, result, BatchResult{Inserted: len(testTransactions) - 1, Skipped: 1})
+	checkPayeeName(t, repo, existing.Id, existing.PayeeName)
+}
+
+func SubTestTransactionRepositoryImpl_CreateBatchUpdate(
+	t *testing.T,
+	repo *TransactionRepositoryImpl,
+	createBatch createBatchFunc,
+) {
+	existing := testTransactions[0]
+	existing.PayeeName = "existing"
+	_, err := repo.CreateBatch([]models.Transaction{existing}, ConflictError)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := createBatch(testTransactions, ConflictUpdate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBatchResult(t, result, BatchResult{Inserted: len(testTransactions) - 1, Updated: 1})
+	checkPayeeName(t, repo, existing.Id, testTransactions[0].PayeeName)
+}
+
+// BenchmarkTransactionRepositoryImpl_CreateBatch saves a large file the way it was
+// saved before COPY was used, compare with BenchmarkTransactionRepositoryImpl_CopyBatch.
+func BenchmarkTransactionRepositoryImpl_CreateBatch(b *testing.B) {
+	benchmarkCreateBatch(
+		b, func(repository TransactionRepository, transactions []models.Transaction) error {
+			for start := 0; start < len(transactions); start += benchmarkCreateBatchSize {
+				end := start + benchmarkCreateBatchSize
+				if end > len(transactions) {
+					end = len(transactions)
+				}
+
+				_, err := repository.CreateBatch(transactions[start:end], ConflictSkip)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	)
+}
+
+func BenchmarkTransactionRepositoryImpl_CopyBatch(b *testing.B) {
+	benchmarkCreateBatch(
+		b, func(repository TransactionRepository, transactions []models.Transaction) error {
+			_, err := repository.CopyBatch(transactions, ConflictSkip)
+			return err
+		},
+	)
+}
+
+const (
+	benchmarkRowCount        = 100000
+	benchmarkCreateBatchSize = 2500
+)
+
+var errBenchmarkRollback = errors.New("rollback")
+
+// benchmarkCreateBatch measures saving of benchmarkRowCount transactions, the
+// database transaction is rolled back after each iteration.
+func benchmarkCreateBatch(b *testing.B, save func(TransactionRepository, []models.Transaction) error) {
+	db, err := openTestDb()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	db.Exec("CREATE SCHEMA rest_api")
+	_ = models.MigrateAll(db)
+
+	transactions := make([]models.Transaction, benchmarkRowCount)
+	for i := range transactions {
+		transactions[i] = testTransactions[i%len(testTransactions)]
+		transactions[i].Id = uint64(1_000_000_000 + i)
+	}
+
+	repo := &TransactionRepositoryImpl{db: db}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = repo.UseTransaction(
+			func(repository TransactionRepository) error {
+				err := save(repository, transactions)
+				if err != nil {
+					return err
+				}
+
+				return errBenchmarkRollback
+			},
+		)
+		if err != errBenchmarkRollback {
+			b.Fatal(err)
+		}
+	}
+}
+
+func checkBatchResult(t *testing.T, actual, expected BatchResult) {
+	if actual != expected {
+		t.Errorf("expected %+v, actual %+v", expected, actual)
+	}
+}
+
+func checkPayeeName(t *testing.T, repo *TransactionRepositoryImpl, id uint64, expected string) {
+	builder := repo.NewFilterBuilder()
+	_ = builder.AddTransactionId(fmt.Sprintf("%d", id))
+	transactions := repo.Filter(builder.GetFilters(), nil, 1, 1)
+	if len(transactions) != 1 {
+		t.Fatalf("expected len %d, actual len %d", 1, len(transactions))
+	}
+
+	if transactions[0].PayeeName != expected {
+		t.Errorf("expected payee name %q, actual %q", expected, transactions[0].PayeeName)
+	}
+}
+
+func TestParseConflictStrategy_Valid(t *testing.T) {
+	for _, expected := range []ConflictStrategy{ConflictError, ConflictSkip, ConflictUpdate} {
+		actual, err := ParseConflictStrategy(string(expected))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if actual != expected {
+			t.Errorf("expected %v, actual %v", expected, actual)
+		}
+	}
+}
+
+func TestParseConflictStrategy_Invalid(t *testing.T) {
+	_, err := ParseConflictStrategy("replace")
+	if err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func Test_removeDuplicateIds(t *testing.T) {
+	first := testTransactions[0]
+	last := testTransactions[0]
+	last.PayeeName = "last"
+	transactions, duplicatesCount := removeDuplicateIds(
+		[]models.Transaction{first, testTransactions[1], last, testTransactions[2]},
+	)
+	if duplicatesCount != 1 {
+		t.Errorf("expected duplicates count %d, actual %d", 1, duplicatesCount)
+	}
+
+	expectedIds := []uint64{testTransactions[1].Id, last.Id, testTransactions[2].Id}
+	actualIds := getIds(transactions)
+	if fmt.Sprint(actualIds) != fmt.Sprint(expectedIds) {
+		t.Errorf("expected ids %v, actual %v", expectedIds, actualIds)
+	}
+
+	if transactions[1].PayeeName != last.PayeeName {
+		t.Errorf("expected the last occurrence to be kept, actual payee name %q", transactions[1].PayeeName)
+	}
+}
+
 func TestNewTransactionRepository_IsNotNil(t *testing.T) {
 	if NewTransactionRepository(nil) == nil {
 		t.Error("transaction repository is nil")
@@ -452,6 +711,38 @@ func TestTransactionFilterBuilderImpl_AddPaymentNarrative_Empty(t *testing.T) {
 	}
 }
 
+func TestTransactionFilterBuilderImpl_AddImportId_Added(t *testing.T) {
+	builder := TransactionFilterBuilderImpl{}
+	err := builder.AddImportId("3")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(builder.filters) != 1 {
+		t.Error("filter was not added")
+	}
+}
+
+func TestTransactionFilterBuilderImpl_AddImportId_InvalidValue(t *testing.T) {
+	builder := TransactionFilterBuilderImpl{}
+	err := builder.AddImportId("hello")
+	if err == nil {
+		t.Error("error is nil")
+	}
+}
+
+func TestTransactionFilterBuilderImpl_AddImportId_Empty(t *testing.T) {
+	builder := TransactionFilterBuilderImpl{}
+	err := builder.AddImportId("")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(builder.filters) != 0 {
+		t.Error("filter was added")
+	}
+}
+
 func openTestDb() (*gorm.DB, error) {
 	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
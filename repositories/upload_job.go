@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"errors"
+
+	"TraineeGolangTestTask/models"
+	"gorm.io/gorm"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+type UploadJobRepository interface {
+	Create(job *models.UploadJob) error
+	Save(job *models.UploadJob) error
+	UpdateProgress(id string, rowsProcessed int) error
+
+	// FindById returns ErrNotFound if the job does not exist.
+	FindById(id string) (*models.UploadJob, error)
+	FindByStates(states ...models.UploadJobState) ([]models.UploadJob, error)
+}
+
+type UploadJobRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewUploadJobRepository(db *gorm.DB) *UploadJobRepositoryImpl {
+	return &UploadJobRepositoryImpl{db: db}
+}
+
+func (r *UploadJobRepositoryImpl) Create(job *models.UploadJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *UploadJobRepositoryImpl) Save(job *models.UploadJob) error {
+	return r.db.Save(job).Error
+}
+
+// UpdateProgress updates only the number of processed rows, so that it can be
+// called concurrently with reading the job.
+func (r *UploadJobRepositoryImpl) UpdateProgress(id string, rowsProcessed int) error {
+	return r.db.Model(&models.UploadJob{}).Where("id = ?", id).Update("rows_processed", rowsProcessed).Error
+}
+
+func (r *UploadJobRepositoryImpl) FindById(id string) (*models.UploadJob, error) {
+	job := &models.UploadJob{}
+	err := r.db.Where("id = ?", id).First(job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// FindByStates returns jobs in any of the given states ordered by the creation time.
+func (r *UploadJobRepositoryImpl) FindByStates(states ...models.UploadJobState) ([]models.UploadJob, error) {
+	var jobs []models.UploadJob
+	err := r.db.Where("state IN ?", states).Order("created_at").Find(&jobs).Error
+	return jobs, err
+}